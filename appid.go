@@ -58,6 +58,23 @@ func ProfileDir(appID string) (string, error) {
 	return dir, nil
 }
 
+// stateDir returns eletrocromo's own per-app state (persisted port, …):
+// $XDG_DATA_HOME/eletrocromo/state/<appID>, kept apart from the Helium profile.
+func stateDir(appID string) (string, error) {
+	if err := ValidateAppID(appID); err != nil {
+		return "", err
+	}
+	base, err := userDataDir()
+	if err != nil {
+		return "", fmt.Errorf("state dir: %w", err)
+	}
+	dir := filepath.Join(base, "eletrocromo", "state", appID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("state dir: %w", err)
+	}
+	return dir, nil
+}
+
 func userDataDir() (string, error) {
 	if v := strings.TrimSpace(os.Getenv("XDG_DATA_HOME")); v != "" {
 		return v, nil
@@ -0,0 +1,290 @@
+package eletrocromo
+
+import (
+	"crypto/subtle"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for App.MaxAuthFailures and App.AuthLockout.
+const (
+	DefaultMaxAuthFailures = 10
+	DefaultAuthLockout     = time.Minute
+)
+
+// AuthReason says why the gate refused a request.
+type AuthReason string
+
+const (
+	// AuthMissing: no token, Bearer header or session cookie (401).
+	AuthMissing AuthReason = "missing_credentials"
+	// AuthInvalid: a presented token or legacy cookie did not match, or a
+	// bootstrap token was replayed/expired by a client without a session
+	// (401). Counts toward throttling.
+	AuthInvalid AuthReason = "invalid_credentials"
+	// AuthSessionInvalid: the session cookie is expired, revoked or forged
+	// (401). Not counted: stale windows are not brute force.
+	AuthSessionInvalid AuthReason = "session_invalid"
+	// AuthInsufficientScope: a read-only token on an unsafe method (403).
+	AuthInsufficientScope AuthReason = "insufficient_scope"
+	// AuthBadHost: Host is not the bound address or AllowedHosts (403).
+	AuthBadHost AuthReason = "bad_host"
+	// AuthCrossOrigin: cross-site unsafe request (403).
+	AuthCrossOrigin AuthReason = "cross_origin"
+	// AuthThrottled: an invalid credential from a source locked out after
+	// repeated invalid credentials (429). Valid ones are still accepted.
+	AuthThrottled AuthReason = "throttled"
+)
+
+// AuthEvent describes one refused request, for App.OnAuthEvent. Path never
+// includes the query (it may hold a token).
+type AuthEvent struct {
+	Time       time.Time
+	RemoteAddr string
+	Method     string
+	Path       string
+	Reason     AuthReason
+}
+
+// authenticate resolves r's credentials. handled reports that a response was
+// already written (bootstrap redirect or throttling); otherwise a non-empty
+// reason means the request is refused.
+func (a *App) authenticate(w http.ResponseWriter, r *http.Request) (info AuthInfo, reason AuthReason, handled bool) {
+	source := remoteHost(r)
+	session := AuthInfo{Name: SessionTokenName}
+	if token := r.URL.Query().Get("token"); token != "" {
+		if a.AuthToken != "" && a.redeemBootstrap(token) {
+			// Single-use bootstrap token from the launch URL: trade it for
+			// the session cookie and drop it from the URL. A replay falls
+			// through and is refused unless its window holds a session.
+			session.Session = a.startSession(w)
+			if redirectWithoutToken(w, r) {
+				return AuthInfo{}, "", true
+			}
+			return session, "", false
+		}
+		if a.matchAuthToken(token) {
+			session.Session = a.startSession(w)
+			return session, "", false
+		}
+		if info, ok := a.cookieAuth(w, r); ok {
+			// A spent or expired bootstrap token (reload, restored tab) from
+			// a window that already has its session: drop it from the URL.
+			if redirectWithoutToken(w, r) {
+				return AuthInfo{}, "", true
+			}
+			return info, "", false
+		}
+		return a.invalid(w, r, source)
+	}
+	if bearer, ok := bearerToken(r); ok {
+		if a.matchAuthToken(bearer) {
+			return session, "", false
+		}
+		if info, ok := a.lookupScoped(bearer); ok {
+			return info, "", false
+		}
+		return a.invalid(w, r, source)
+	}
+	if info, ok := a.sessionFromCookie(w, r); ok {
+		return info, "", false
+	}
+	if cookie, err := r.Cookie(AUTH_COOKIE_KEY); err == nil {
+		if a.matchAuthToken(cookie.Value) {
+			return session, "", false
+		}
+		return a.invalid(w, r, source)
+	}
+	if _, err := r.Cookie(sessionCookieName(a.ID)); err == nil && a.sessionStore() != nil {
+		return AuthInfo{}, AuthSessionInvalid, false
+	}
+	return AuthInfo{}, AuthMissing, false
+}
+
+// cookieAuth accepts a valid session cookie, or the legacy AuthToken cookie.
+func (a *App) cookieAuth(w http.ResponseWriter, r *http.Request) (AuthInfo, bool) {
+	if info, ok := a.sessionFromCookie(w, r); ok {
+		return info, true
+	}
+	if cookie, err := r.Cookie(AUTH_COOKIE_KEY); err == nil && a.matchAuthToken(cookie.Value) {
+		return AuthInfo{Name: SessionTokenName}, true
+	}
+	return AuthInfo{}, false
+}
+
+// matchAuthToken compares in constant time and fails closed when AuthToken
+// is unset: ConstantTimeCompare("", "") would otherwise accept
+// unauthenticated requests (ServeHTTP without Run).
+func (a *App) matchAuthToken(token string) bool {
+	return a.AuthToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.AuthToken)) == 1
+}
+
+// invalid refuses a credential that did not verify: it counts toward the
+// source's lockout, or gets 429 while the source is locked out. Credentials
+// that verify are never throttled: they are high-entropy, and every loopback
+// client shares one source, so locking them out would only let any local
+// process deny the real window and clients.
+func (a *App) invalid(w http.ResponseWriter, r *http.Request, source string) (AuthInfo, AuthReason, bool) {
+	now := time.Now()
+	if until, locked := a.throttle.locked(source, now); locked {
+		a.emitAuthEvent(r, AuthThrottled)
+		a.applySecurityHeaders(w, r)
+		w.Header().Set("Retry-After", strconv.Itoa(int(until.Sub(now).Seconds())+1))
+		w.WriteHeader(http.StatusTooManyRequests)
+		if _, err := io.WriteString(w, "too many attempts"); err != nil {
+			return AuthInfo{}, AuthThrottled, true
+		}
+		return AuthInfo{}, AuthThrottled, true
+	}
+	a.throttle.fail(source, now, a.maxAuthFailures(), a.authLockout())
+	return AuthInfo{}, AuthInvalid, false
+}
+
+// refuse writes the 401 (via OnUnauthorized when set) or 403 for reason,
+// with the baseline security headers.
+func (a *App) refuse(w http.ResponseWriter, r *http.Request, reason AuthReason) {
+	a.emitAuthEvent(r, reason)
+	r = a.applySecurityHeaders(w, r)
+	switch reason {
+	case AuthMissing, AuthInvalid, AuthSessionInvalid:
+	default:
+		w.WriteHeader(http.StatusForbidden)
+		if _, err := io.WriteString(w, "forbidden"); err != nil {
+			return
+		}
+		return
+	}
+	if a.OnUnauthorized != nil {
+		uw := &unauthorizedWriter{ResponseWriter: w}
+		a.OnUnauthorized.ServeHTTP(uw, r)
+		if !uw.wroteHeader {
+			uw.WriteHeader(http.StatusUnauthorized)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	if _, err := io.WriteString(w, "forbidden"); err != nil {
+		return
+	}
+}
+
+func (a *App) emitAuthEvent(r *http.Request, reason AuthReason) {
+	if a.OnAuthEvent == nil {
+		return
+	}
+	a.OnAuthEvent(AuthEvent{
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Reason:     reason,
+	})
+}
+
+func (a *App) maxAuthFailures() int {
+	if a.MaxAuthFailures == 0 {
+		return DefaultMaxAuthFailures
+	}
+	return a.MaxAuthFailures
+}
+
+func (a *App) authLockout() time.Duration {
+	if a.AuthLockout <= 0 {
+		return DefaultAuthLockout
+	}
+	return a.AuthLockout
+}
+
+// unauthorizedWriter makes OnUnauthorized respond 401 unless it picks a
+// status itself.
+type unauthorizedWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (u *unauthorizedWriter) WriteHeader(code int) {
+	u.wroteHeader = true
+	u.ResponseWriter.WriteHeader(code)
+}
+
+func (u *unauthorizedWriter) Write(b []byte) (int, error) {
+	if !u.wroteHeader {
+		u.WriteHeader(http.StatusUnauthorized)
+	}
+	return u.ResponseWriter.Write(b)
+}
+
+func (u *unauthorizedWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+// remoteHost is the throttling key. On loopback every local client shares
+// 127.0.0.1, so in practice the lockout is per app; see invalid for why it
+// only refuses credentials that do not verify.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authThrottle counts invalid credentials per source over a sliding window
+// of lockout length; reaching the limit locks the source out for lockout.
+// The zero value is ready to use.
+type authThrottle struct {
+	mu      sync.Mutex
+	sources map[string]*authFailures
+}
+
+type authFailures struct {
+	times []time.Time // failures inside the window
+	until time.Time   // locked out until
+}
+
+func (t *authThrottle) fail(source string, now time.Time, limit int, lockout time.Duration) {
+	if limit < 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sources == nil {
+		t.sources = make(map[string]*authFailures)
+	}
+	f := t.sources[source]
+	if f == nil {
+		f = &authFailures{}
+		t.sources[source] = f
+	}
+	kept := f.times[:0]
+	for _, at := range f.times {
+		if now.Sub(at) < lockout {
+			kept = append(kept, at)
+		}
+	}
+	f.times = append(kept, now)
+	if len(f.times) >= limit {
+		f.until = now.Add(lockout)
+		f.times = f.times[:0]
+	}
+}
+
+func (t *authThrottle) locked(source string, now time.Time) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f := t.sources[source]
+	if f == nil {
+		return time.Time{}, false
+	}
+	if now.Before(f.until) {
+		return f.until, true
+	}
+	if len(f.times) == 0 {
+		delete(t.sources, source)
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,229 @@
+package eletrocromo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeHTTP_OnUnauthorized(t *testing.T) {
+	app := &App{
+		AuthToken: "secret-token",
+		Handler:   http.NotFoundHandler(),
+		OnUnauthorized: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := io.WriteString(w, "reopen from the app"); err != nil {
+				return
+			}
+		}),
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodGet, "/", "", ""))
+	if w.Code != http.StatusUnauthorized || w.Body.String() != "reopen from the app" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Fatal("unauthorized page served without security headers")
+	}
+
+	app.OnUnauthorized = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	})
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodGet, "/", "", "wrong"))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("custom status: got %d", w.Code)
+	}
+
+	// 403s are not the handler's business.
+	app.setBoundHost("127.0.0.1:4242")
+	req := newAuthRequest(http.MethodGet, "/", "", "secret-token")
+	req.Host = "evil.example"
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Body.String() != "forbidden" {
+		t.Fatalf("bad host: got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Security-Policy") == "" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("403 served without security headers")
+	}
+
+	app.OnUnauthorized = nil
+	req = newAuthRequest(http.MethodGet, "/", "", "")
+	req.Host = "127.0.0.1:4242"
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Security-Policy") == "" {
+		t.Fatalf("default 401: got %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestServeHTTP_AuthEvents(t *testing.T) {
+	var events []AuthEvent
+	app := &App{
+		AuthToken:   "secret-token",
+		Handler:     http.NotFoundHandler(),
+		OnAuthEvent: func(e AuthEvent) { events = append(events, e) },
+	}
+	readOnly, err := app.MintToken("cli", ScopeReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.setBoundHost("127.0.0.1:4242")
+
+	send := func(req *http.Request) {
+		if req.Host == "example.com" {
+			req.Host = "127.0.0.1:4242"
+		}
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send(newAuthRequest(http.MethodGet, "/a", "", ""))
+	send(newAuthRequest(http.MethodGet, "/b", "guess", ""))
+	req := httptest.NewRequest(http.MethodPost, "/c", nil)
+	req.Header.Set("Authorization", "Bearer "+readOnly)
+	send(req)
+	req = newAuthRequest(http.MethodGet, "/d", "", "secret-token")
+	req.Host = "evil.example"
+	send(req)
+	req = newAuthRequest(http.MethodPost, "/e", "", "secret-token")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	send(req)
+	send(newAuthRequest(http.MethodGet, "/ok", "", "secret-token"))
+
+	want := []struct {
+		path   string
+		reason AuthReason
+	}{
+		{"/a", AuthMissing},
+		{"/b", AuthInvalid},
+		{"/c", AuthInsufficientScope},
+		{"/d", AuthBadHost},
+		{"/e", AuthCrossOrigin},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v", events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Path != w.path || e.Reason != w.reason {
+			t.Errorf("event %d = %s %s, want %s %s", i, e.Path, e.Reason, w.path, w.reason)
+		}
+		if e.RemoteAddr == "" || e.Method == "" || e.Time.IsZero() {
+			t.Errorf("event %d incomplete: %+v", i, e)
+		}
+		if strings.Contains(e.Path, "guess") {
+			t.Errorf("event %d leaks the query: %q", i, e.Path)
+		}
+	}
+}
+
+func TestServeHTTP_ThrottlesInvalidCredentials(t *testing.T) {
+	var throttled int
+	app := &App{
+		AuthToken:       "secret-token",
+		Handler:         http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		MaxAuthFailures: 3,
+		AuthLockout:     time.Minute,
+		OnAuthEvent: func(e AuthEvent) {
+			if e.Reason == AuthThrottled {
+				throttled++
+			}
+		},
+	}
+	status := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	// Missing credentials are not guesses.
+	for range 5 {
+		if w := status(newAuthRequest(http.MethodGet, "/", "", "")); w.Code != http.StatusUnauthorized {
+			t.Fatalf("missing: got %d", w.Code)
+		}
+	}
+	for i := range 3 {
+		if w := status(newAuthRequest(http.MethodGet, "/", "guess", "")); w.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: got %d", i, w.Code)
+		}
+	}
+	w := status(newAuthRequest(http.MethodGet, "/", "guess", ""))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked out: got %d Retry-After=%q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Fatal("429 served without security headers")
+	}
+	if throttled != 1 {
+		t.Fatalf("throttled events = %d", throttled)
+	}
+	// Credentials that verify are not throttled.
+	if w := status(newAuthRequest(http.MethodGet, "/", "secret-token", "")); w.Code != http.StatusOK {
+		t.Fatalf("valid token during lockout: got %d", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	if w := status(req); w.Code != http.StatusOK {
+		t.Fatalf("valid Bearer during lockout: got %d", w.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer guess")
+	if w := status(req); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("invalid Bearer during lockout: got %d", w.Code)
+	}
+	scoped, err := app.MintToken("cli", ScopeFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+scoped)
+	if w := status(req); w.Code != http.StatusOK {
+		t.Fatalf("minted token during lockout: got %d", w.Code)
+	}
+	if w := status(newAuthRequest(http.MethodGet, "/", app.mintBootstrap(), "")); w.Code != http.StatusSeeOther {
+		t.Fatalf("fresh bootstrap token during lockout: got %d", w.Code)
+	}
+
+	// Another source is unaffected.
+	req = newAuthRequest(http.MethodGet, "/", "secret-token", "")
+	req.RemoteAddr = "10.0.0.9:1234"
+	if w := status(req); w.Code != http.StatusOK {
+		t.Fatalf("other source: got %d", w.Code)
+	}
+}
+
+func TestServeHTTP_ThrottleKeepsSessionWorking(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	app, link, stop := sessionApp(t, "br.tec.lew.test.throttle")
+	defer stop()
+	app.MaxAuthFailures = 2
+	client := sessionClient(t)
+	if code, _ := get(t, client, link); code != http.StatusOK {
+		t.Fatalf("bootstrap: %d", code)
+	}
+	base := origin(t, link)
+	for range 2 {
+		if code, _ := get(t, http.DefaultClient, base+"?token=guess"); code != http.StatusUnauthorized {
+			t.Fatalf("guess: %d", code)
+		}
+	}
+	if code, _ := get(t, http.DefaultClient, base+"?token=guess"); code != http.StatusTooManyRequests {
+		t.Fatalf("locked out: %d", code)
+	}
+	if code, _ := get(t, client, base); code != http.StatusOK {
+		t.Fatalf("session during lockout: %d", code)
+	}
+}
+
+func TestServeHTTP_ThrottleDisabled(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler(), MaxAuthFailures: -1}
+	for range DefaultMaxAuthFailures + 5 {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, newAuthRequest(http.MethodGet, "/", "guess", ""))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("got %d", w.Code)
+		}
+	}
+}
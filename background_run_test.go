@@ -7,11 +7,18 @@ import (
 	"time"
 )
 
+// afterRun leaves app as a returned Run does: BackgroundRun starts tasks at
+// once on App.Context instead of queueing them for Run.
+func afterRun(app *App) *App {
+	app.ran = true
+	return app
+}
+
 // TestBackgroundRun_TracksWaitGroup ensures WaitGroup.Add runs before the
 // task goroutine is scheduled, so Wait cannot return early (the race that
 // previously needed time.Sleep in App.Run).
 func TestBackgroundRun_TracksWaitGroup(t *testing.T) {
-	app := &App{Context: t.Context()}
+	app := afterRun(&App{Context: t.Context()})
 	started := make(chan struct{})
 	release := make(chan struct{})
 
@@ -54,7 +61,7 @@ func TestBackgroundRun_TracksWaitGroup(t *testing.T) {
 func TestBackgroundRun_UsesAppContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
-	app := &App{Context: ctx}
+	app := afterRun(&App{Context: ctx})
 
 	var sawCancel atomic.Bool
 	if err := app.BackgroundRun(FunctionTask(func(taskCtx context.Context) error {
@@ -74,7 +81,7 @@ func TestBackgroundRun_UsesAppContext(t *testing.T) {
 
 // Nil Context must not panic inside task.Run; same default as App.Run.
 func TestBackgroundRun_NilContext_UsesBackground(t *testing.T) {
-	app := &App{} // Context intentionally unset
+	app := afterRun(&App{}) // Context intentionally unset
 	var gotNonNil atomic.Bool
 	err := app.BackgroundRun(FunctionTask(func(taskCtx context.Context) error {
 		if taskCtx != nil {
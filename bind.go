@@ -0,0 +1,160 @@
+package eletrocromo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNonLoopback is returned when an injected listener is not bound to a
+	// loopback address (SPEC: the server is never reachable off-host).
+	ErrNonLoopback = errors.New("listener is not bound to a loopback address")
+	// ErrInvalidPort is returned for a Port/PreferredPort outside 1–65535.
+	ErrInvalidPort = errors.New("invalid port")
+	// ErrPortInUse is returned when the fixed App.Port is already taken.
+	ErrPortInUse = errors.New("port already in use")
+)
+
+// loopbackHost is where Run binds. 127.0.0.1 rather than localhost/[::1]:
+// Android WebView + networkSecurityConfig only trust the IPv4 literal.
+const loopbackHost = "127.0.0.1"
+
+// portFileName holds the last bound port under the per-ID state dir.
+const portFileName = "port"
+
+// listen returns the loopback listener for Run. Precedence: App.Listener,
+// App.Port (fixed), the persisted port (PersistPort), App.PreferredPort,
+// then an ephemeral port.
+func (a *App) listen() (net.Listener, error) {
+	if a.Listener != nil {
+		if err := checkLoopback(a.Listener.Addr()); err != nil {
+			return nil, err
+		}
+		return a.Listener, nil
+	}
+	if a.Port != 0 {
+		if err := checkPort(a.Port); err != nil {
+			return nil, err
+		}
+		ln, err := listenPort(a.Port)
+		if isAddrInUse(err) {
+			return nil, fmt.Errorf("%w: %d", ErrPortInUse, a.Port)
+		}
+		return ln, err
+	}
+	if a.PreferredPort != 0 {
+		if err := checkPort(a.PreferredPort); err != nil {
+			return nil, err
+		}
+	}
+
+	var candidates []int
+	if a.PersistPort {
+		if p, ok := readPersistedPort(a.ID); ok {
+			candidates = append(candidates, p)
+		}
+	}
+	if a.PreferredPort != 0 {
+		candidates = append(candidates, a.PreferredPort)
+	}
+	for _, p := range candidates {
+		ln, err := listenPort(p)
+		if err == nil {
+			return a.persistPort(ln), nil
+		}
+		// Taken or not permitted: fall through to the next choice. The
+		// origin changes, so browser storage from the old port is not visible.
+		a.logger(phaseServe).Info("port unavailable; trying next", "port", p, "err", err)
+	}
+	ln, err := listenPort(0)
+	if err != nil {
+		return nil, err
+	}
+	return a.persistPort(ln), nil
+}
+
+func listenPort(port int) (net.Listener, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(loopbackHost, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return ln, nil
+}
+
+func checkPort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%w: %d", ErrInvalidPort, port)
+	}
+	return nil
+}
+
+func checkLoopback(addr net.Addr) error {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || !tcp.IP.IsLoopback() {
+		return fmt.Errorf("%w: %s", ErrNonLoopback, addr)
+	}
+	return nil
+}
+
+// listenerBase is the scheme://host:port the app is served on.
+func listenerBase(ln net.Listener, tls bool) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	host, port := loopbackHost, ""
+	if tcp, ok := ln.Addr().(*net.TCPAddr); ok {
+		host = tcp.IP.String()
+		port = strconv.Itoa(tcp.Port)
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// persistPort records ln's port for the next Run when PersistPort is set.
+// Failing to write only costs origin stability, so it is logged.
+func (a *App) persistPort(ln net.Listener) net.Listener {
+	if !a.PersistPort {
+		return ln
+	}
+	tcp, ok := ln.Addr().(*net.TCPAddr)
+	if !ok {
+		return ln
+	}
+	path, err := portFilePath(a.ID)
+	if err == nil {
+		err = os.WriteFile(path, []byte(strconv.Itoa(tcp.Port)+"\n"), 0o600)
+	}
+	if err != nil {
+		a.logger(phaseServe).Warn("persist port failed", "err", err)
+	}
+	return ln
+}
+
+func readPersistedPort(appID string) (int, bool) {
+	path, err := portFilePath(appID)
+	if err != nil {
+		return 0, false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || checkPort(p) != nil {
+		return 0, false
+	}
+	return p, true
+}
+
+func portFilePath(appID string) (string, error) {
+	dir, err := stateDir(appID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, portFileName), nil
+}
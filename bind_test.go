@@ -0,0 +1,132 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// freePort returns a loopback port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func linkPort(t *testing.T, link string) int {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Hostname() != "127.0.0.1" {
+		t.Fatalf("link host = %q, want 127.0.0.1", u.Hostname())
+	}
+	p, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// runOnce starts a NoUI Run, returns the bound port, and stops it.
+func runOnce(t *testing.T, opts ...Option) int {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	app := New(http.NotFoundHandler(), append([]Option{WithContext(ctx)}, opts...)...)
+	link, errCh := runNoUI(t, app)
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	return linkPort(t, link)
+}
+
+func TestBind_FixedPort(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	port := freePort(t)
+	if got := runOnce(t, WithID("br.tec.lew.test.bind"), WithPort(port)); got != port {
+		t.Fatalf("bound %d, want %d", got, port)
+	}
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = busy.Close() }()
+	app := New(http.NotFoundHandler(), WithID("br.tec.lew.test.bind"),
+		WithPort(busy.Addr().(*net.TCPAddr).Port), WithNoUI(true))
+	if err := app.Run(); !errors.Is(err, ErrPortInUse) {
+		t.Fatalf("want ErrPortInUse, got %v", err)
+	}
+}
+
+func TestBind_PreferredPortFallsBack(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	port := freePort(t)
+	if got := runOnce(t, WithID("br.tec.lew.test.bind"), WithPreferredPort(port)); got != port {
+		t.Fatalf("bound %d, want preferred %d", got, port)
+	}
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = busy.Close() }()
+	taken := busy.Addr().(*net.TCPAddr).Port
+	if got := runOnce(t, WithID("br.tec.lew.test.bind"), WithPreferredPort(taken)); got == taken || got == 0 {
+		t.Fatalf("bound %d with preferred %d taken", got, taken)
+	}
+}
+
+func TestBind_PersistentPortIsReused(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	first := runOnce(t, WithID("br.tec.lew.test.persist"), WithPersistentPort())
+	second := runOnce(t, WithID("br.tec.lew.test.persist"), WithPersistentPort())
+	if first != second {
+		t.Fatalf("persisted port not reused: %d then %d", first, second)
+	}
+}
+
+func TestBind_InjectedListener(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ln.Addr().(*net.TCPAddr).Port
+	if got := runOnce(t, WithID("br.tec.lew.test.bind"), WithListener(ln)); got != want {
+		t.Fatalf("served on %d, want injected %d", got, want)
+	}
+}
+
+func TestBind_RefusesNonLoopbackAndBadPorts(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ln, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Skipf("cannot listen on wildcard: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	app := New(http.NotFoundHandler(), WithID("br.tec.lew.test.bind"), WithListener(ln), WithNoUI(true))
+	if err := app.Run(); !errors.Is(err, ErrNonLoopback) {
+		t.Fatalf("wildcard listener: want ErrNonLoopback, got %v", err)
+	}
+
+	for _, opt := range []Option{WithPort(70000), WithPreferredPort(-1)} {
+		app := New(http.NotFoundHandler(), WithID("br.tec.lew.test.bind"), opt, WithNoUI(true))
+		if err := app.Run(); !errors.Is(err, ErrInvalidPort) {
+			t.Fatalf("want ErrInvalidPort, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build unix
+
+package eletrocromo
+
+import (
+	"errors"
+	"syscall"
+)
+
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package eletrocromo
+
+import (
+	"errors"
+	"syscall"
+)
+
+// wsaeAddrInUse is WSAEADDRINUSE, what Winsock returns for a taken port;
+// syscall.EADDRINUSE is only Go's stand-in there and never matches it.
+const wsaeAddrInUse = syscall.Errno(10048)
+
+func isAddrInUse(err error) bool {
+	return errors.Is(err, wsaeAddrInUse) || errors.Is(err, syscall.EADDRINUSE)
+}
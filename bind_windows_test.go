@@ -0,0 +1,16 @@
+//go:build windows
+
+package eletrocromo
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestIsAddrInUse_Winsock(t *testing.T) {
+	err := &net.OpError{Op: "listen", Net: "tcp", Err: os.NewSyscallError("bind", wsaeAddrInUse)}
+	if !isAddrInUse(err) {
+		t.Fatalf("WSAEADDRINUSE not recognised: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package eletrocromo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// bootstrapTTL bounds how long a minted bootstrap token stays redeemable:
+// long enough for a cold Helium start or the Android WebView handoff.
+var bootstrapTTL = 60 * time.Second
+
+// mintBootstrap returns a fresh single-use bootstrap token. It goes in the
+// launch URL (?token=…) instead of AuthToken, so argv and history only ever
+// hold a spent value. Stored hashed; expired entries are pruned here.
+func (a *App) mintBootstrap() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b[:])
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.bootstrap == nil {
+		a.bootstrap = make(map[[sha256.Size]byte]time.Time)
+	}
+	for k, exp := range a.bootstrap {
+		if now.After(exp) {
+			delete(a.bootstrap, k)
+		}
+	}
+	a.bootstrap[sha256.Sum256([]byte(nonce))] = now.Add(bootstrapTTL)
+	return nonce
+}
+
+// redeemBootstrap consumes nonce; it reports false for unknown, expired or
+// already redeemed tokens. Lookup is by hash, so timing reveals nothing
+// about live tokens.
+func (a *App) redeemBootstrap(nonce string) bool {
+	key := sha256.Sum256([]byte(nonce))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	exp, ok := a.bootstrap[key]
+	if !ok {
+		return false
+	}
+	delete(a.bootstrap, key)
+	return time.Now().Before(exp)
+}
+
+// redirectWithoutToken 303s GET/HEAD to the same path minus ?token= so the
+// bootstrap value does not stay in the address bar or history. Reports
+// whether it wrote a response.
+func redirectWithoutToken(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	q := r.URL.Query()
+	q.Del("token")
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	u.Scheme, u.Host, u.User = "", "", nil
+	// A leading "//" would make Location protocol-relative (off-host).
+	u.Path = "/" + strings.TrimLeft(u.Path, "/")
+	u.RawPath = ""
+	http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+	return true
+}
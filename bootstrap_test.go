@@ -0,0 +1,164 @@
+package eletrocromo
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionClient follows the bootstrap 303 with the session cookie, like the
+// Helium window or Android WebView does.
+func sessionClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+func TestBootstrap_ExchangeRedirectsAndRefusesReplay(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler()}
+	nonce := app.mintBootstrap()
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notes?id=7&token="+nonce, nil))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("exchange: want 303, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/notes?id=7" {
+		t.Fatalf("Location = %q, want token stripped", loc)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == AUTH_COOKIE_KEY {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value != app.AuthToken || !cookie.HttpOnly {
+		t.Fatalf("exchange did not set the session cookie: %+v", cookie)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notes?token="+nonce, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("replay: want 401, got %d", w.Code)
+	}
+}
+
+func TestBootstrap_SpentTokenWithCookieRedirects(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler()}
+	nonce := app.mintBootstrap()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?token="+nonce, nil))
+	cookies := w.Result().Cookies()
+
+	// Reloading the launch URL from the window that redeemed it.
+	req := httptest.NewRequest(http.MethodGet, "/notes?id=7&token="+nonce, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/notes?id=7" {
+		t.Fatalf("spent token with cookie: got %d Location %q, want 303 without the token", w.Code, w.Header().Get("Location"))
+	}
+	app.throttle.mu.Lock()
+	failures := len(app.throttle.sources)
+	app.throttle.mu.Unlock()
+	if failures != 0 {
+		t.Fatal("a spent token with a valid cookie counted as a failure")
+	}
+}
+
+func TestBootstrap_ExpiredTokenRefused(t *testing.T) {
+	orig := bootstrapTTL
+	bootstrapTTL = -time.Second
+	t.Cleanup(func() { bootstrapTTL = orig })
+
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler()}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?token="+app.mintBootstrap(), nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expired: want 401, got %d", w.Code)
+	}
+}
+
+func TestBootstrap_RedirectStaysOnHost(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler()}
+	req := httptest.NewRequest(http.MethodGet, "/?token="+app.mintBootstrap(), nil)
+	req.URL.Path = "//evil.example/x"
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if loc := w.Header().Get("Location"); loc != "/evil.example/x" {
+		t.Fatalf("Location = %q, want a same-host path", loc)
+	}
+}
+
+func TestBootstrap_PostIsServedWithoutRedirect(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?token="+app.mintBootstrap(), nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("POST exchange: want handler's 204, got %d", w.Code)
+	}
+}
+
+func TestRun_LaunchURLCarriesFreshBootstrapToken(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.bootstrap"),
+		WithContext(ctx),
+		WithBrowserHost(host),
+		WithBackground(true),
+		WithAuthToken("session-secret"),
+	)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for countLaunches(t, launches) < 1 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if err := app.OpenWindow(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(launches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 launches, got %q", lines)
+	}
+	tokenOf := func(line string) string {
+		_, after, ok := strings.Cut(line, "?token=")
+		if !ok {
+			t.Fatalf("launch argv has no bootstrap token: %q", line)
+		}
+		return strings.Fields(after)[0]
+	}
+	if strings.Contains(string(b), "session-secret") {
+		t.Fatalf("AuthToken leaked into Helium argv: %q", b)
+	}
+	if tokenOf(lines[0]) == tokenOf(lines[1]) {
+		t.Fatal("each launch should mint its own bootstrap token")
+	}
+}
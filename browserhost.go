@@ -0,0 +1,106 @@
+package eletrocromo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// BrowserHost finds and starts the browser behind the app window
+// (App.BrowserHost). The default is HeliumHost; embedders can pin a binary
+// shipped by their installer, wrap the launch in a sandbox, or use a fake
+// in tests.
+type BrowserHost interface {
+	// Resolve returns the browser binary Launch will start. Run calls it
+	// once, before binding any port; it may download (see HeliumHost).
+	Resolve(ctx context.Context) (string, error)
+	// Launch starts bin for spec and returns once the process is running.
+	// Run treats a window whose Wait returns within the startup grace as
+	// a failed launch (ErrHeliumLaunch). ctx bounds the launch, not the
+	// window's life.
+	Launch(ctx context.Context, bin string, spec LaunchSpec) (BrowserWindow, error)
+}
+
+// LaunchSpec is what Run asks a BrowserHost to open.
+type LaunchSpec struct {
+	// URL is the app's launch URL. It carries a single-use bootstrap token:
+	// do not log it.
+	URL string
+	// ProfileDir is the per-App.ID browser profile (--user-data-dir).
+	ProfileDir string
+	// Flags are the browser flags from WindowOptions and the remembered
+	// window bounds.
+	Flags []string
+}
+
+// BrowserWindow is a launched browser process. A window may also implement
+// Pid() int (passed to App.OnWindowOpened) and DevTools() *DevTools (served
+// by App.DevTools).
+type BrowserWindow interface {
+	// Wait blocks until the process exits and returns why (nil for a clean
+	// exit). Run calls it exactly once.
+	Wait() error
+	// Stop ends the process and its helpers. It may be called more than
+	// once and after the process exited.
+	Stop()
+}
+
+// HeliumHost is the default BrowserHost: Helium from PATH, else ensured via
+// workspaced, opened in --app mode on the app's own profile.
+type HeliumHost struct {
+	// Path pins the Helium binary (e.g. one your installer ships); Resolve
+	// then only checks that it is executable.
+	Path string
+	// WorkspacedPath pins the workspaced binary used for ensure
+	// (ELETROCROMO_WORKSPACED otherwise).
+	WorkspacedPath string
+	// Ensure, when non-nil, turns ensure via workspaced on or off,
+	// overriding ELETROCROMO_NO_ENSURE.
+	Ensure *bool
+	// Logger receives ensure progress; nil means slog.Default.
+	Logger *slog.Logger
+
+	// Test seams; nil means exec.LookPath, runCommand and bootstrapGet.
+	lookPath      func(file string) (string, error)
+	commandOutput func(ctx context.Context, name string, args ...string) ([]byte, error)
+	httpGet       func(ctx context.Context, url string) (*http.Response, error)
+}
+
+// Resolve finds Helium (see ResolveBrowserHost), or checks Path.
+func (h *HeliumHost) Resolve(ctx context.Context) (string, error) {
+	if path := strings.TrimSpace(h.Path); path != "" {
+		bin, err := h.look(path)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrNoChromium, err)
+		}
+		return bin, nil
+	}
+	return h.resolve(ctx)
+}
+
+// Launch starts Helium on spec.ProfileDir with spec.Flags and --app=URL. On
+// Unix it runs in its own process group (Stop kills the tree) and gets a
+// DevTools pipe.
+func (h *HeliumHost) Launch(_ context.Context, bin string, spec LaunchSpec) (BrowserWindow, error) {
+	w, err := startAppWindow(bin, spec.URL, spec.ProfileDir, true, spec.Flags...)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// browserHost is App.BrowserHost, or a HeliumHost built from the App's
+// ensure and workspaced settings (unset ones fall back to the ELETROCROMO_*
+// env defaults). Run builds it once and keeps it for every launch.
+func (a *App) browserHost() BrowserHost {
+	if a.BrowserHost != nil {
+		return a.BrowserHost
+	}
+	return &HeliumHost{
+		WorkspacedPath: a.WorkspacedPath,
+		Ensure:         a.ensure,
+		Logger:         a.logger(phaseEnsure),
+	}
+}
@@ -0,0 +1,207 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHost is a BrowserHost with no process behind it.
+type fakeHost struct {
+	bin       string
+	err       error // from Resolve
+	crash     error // windows exit at once with this
+	mu        sync.Mutex
+	resolved  int
+	launches  []LaunchSpec
+	windows   []*fakeWindow
+	launchBin string
+	// browser, when set, gives each window a DevTools answered like this
+	// (see fakeBrowser); browsers holds their far ends.
+	browser  func(cdpRequest, map[string]any) (any, string)
+	t        *testing.T
+	browsers []*fakeBrowser
+}
+
+func (h *fakeHost) Resolve(context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resolved++
+	return h.bin, h.err
+}
+
+func (h *fakeHost) Launch(_ context.Context, bin string, spec LaunchSpec) (BrowserWindow, error) {
+	w := &fakeWindow{exited: make(chan struct{})}
+	if h.crash != nil {
+		w.exit(h.crash)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.browser != nil {
+		var fb *fakeBrowser
+		w.dt, fb = newFakeBrowserPipe(h.t, h.browser)
+		h.browsers = append(h.browsers, fb)
+	}
+	h.launchBin = bin
+	h.launches = append(h.launches, spec)
+	h.windows = append(h.windows, w)
+	return w, nil
+}
+
+func (h *fakeHost) window(i int) *fakeWindow {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i >= len(h.windows) {
+		return nil
+	}
+	return h.windows[i]
+}
+
+type fakeWindow struct {
+	once    sync.Once
+	exited  chan struct{}
+	err     error
+	stopped bool
+	dt      *DevTools
+}
+
+func (w *fakeWindow) exit(err error) {
+	w.once.Do(func() {
+		w.err = err
+		close(w.exited)
+	})
+}
+
+func (w *fakeWindow) Wait() error {
+	<-w.exited
+	return w.err
+}
+
+func (w *fakeWindow) Stop() {
+	w.once.Do(func() {
+		w.stopped = true
+		close(w.exited)
+	})
+}
+
+func (w *fakeWindow) Pid() int { return 4242 }
+
+func (w *fakeWindow) DevTools() *DevTools { return w.dt }
+
+func fakeHostEnv(t *testing.T) {
+	t.Helper()
+	orig := heliumStartupGrace
+	t.Cleanup(func() { heliumStartupGrace = orig })
+	heliumStartupGrace = 50 * time.Millisecond
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	shortRuntimeDir(t)
+}
+
+func TestRun_CustomBrowserHost(t *testing.T) {
+	fakeHostEnv(t)
+	host := &fakeHost{bin: "/opt/acme/helium"}
+	pids := make(chan int, 1)
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.browser_host"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+		WithWindow(WindowOptions{DarkMode: true}),
+		WithOnWindowOpened(func(pid int) {
+			pids <- pid
+		}),
+	)
+	errc := make(chan error, 1)
+	go func() { errc <- app.Run() }()
+
+	select {
+	case pid := <-pids:
+		if pid != 4242 {
+			t.Fatalf("pid = %d", pid)
+		}
+	case err := <-errc:
+		t.Fatalf("Run returned early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("window never opened")
+	}
+	// The user closes the window: window-owned lifetime ends Run.
+	host.window(0).exit(nil)
+	if err := waitRun(t, errc); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := ProfileDir("br.tec.lew.test.browser_host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.mu.Lock()
+	defer host.mu.Unlock()
+	if host.resolved != 1 || host.launchBin != "/opt/acme/helium" || len(host.launches) != 1 {
+		t.Fatalf("resolved %d, launched %q %d times", host.resolved, host.launchBin, len(host.launches))
+	}
+	spec := host.launches[0]
+	if !strings.Contains(spec.URL, "/?token=") || spec.ProfileDir != profile {
+		t.Fatalf("spec = %+v", spec)
+	}
+	if len(spec.Flags) != 1 || spec.Flags[0] != "--force-dark-mode" {
+		t.Fatalf("flags = %q", spec.Flags)
+	}
+}
+
+func TestRun_CustomBrowserHostFailures(t *testing.T) {
+	fakeHostEnv(t)
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.browser_host_fail"),
+		WithContext(t.Context()),
+		WithBrowserHost(&fakeHost{err: ErrNoChromium}),
+	)
+	if err := app.Run(); !errors.Is(err, ErrNoChromium) {
+		t.Fatalf("resolve failure: err = %v", err)
+	}
+
+	crash := errors.New("sandbox refused")
+	app.BrowserHost = &fakeHost{bin: "helium", crash: crash}
+	if err := app.Run(); !errors.Is(err, ErrHeliumLaunch) || !errors.Is(err, crash) {
+		t.Fatalf("launch failure: err = %v", err)
+	}
+}
+
+func TestRun_CustomBrowserHostStoppedOnShutdown(t *testing.T) {
+	fakeHostEnv(t)
+	host := &fakeHost{bin: "helium"}
+	ctx, cancel := context.WithCancel(t.Context())
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.browser_host_stop"),
+		WithContext(ctx),
+		WithBrowserHost(host),
+		WithOnWindowOpened(func(int) { cancel() }),
+	)
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if w := host.window(0); w == nil || !w.stopped {
+		t.Fatal("window not stopped by shutdown")
+	}
+	if _, err := app.DevTools(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("DevTools after Run err = %v", err)
+	}
+}
+
+func TestHeliumHost_PinnedPath(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "helium")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&HeliumHost{Path: bin}).Resolve(t.Context())
+	if err != nil || got != bin {
+		t.Fatalf("Resolve = %q, %v", got, err)
+	}
+	if _, err := (&HeliumHost{Path: bin + ".missing"}).Resolve(t.Context()); !errors.Is(err, ErrNoChromium) {
+		t.Fatalf("missing pinned path err = %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"os"
 	"os/exec"
@@ -36,15 +37,17 @@ var ErrUserDataDirRequired = errors.New("user data dir is required")
 // Immediate crash (bad flags, missing libs, wrapper exit) surfaces as Run error.
 var heliumStartupGrace = 2 * time.Second
 
-// lookPath is exec.LookPath; tests may override.
-var lookPath = exec.LookPath
-
 // GetChromium returns a local Helium binary path if present on PATH.
 // It does not download or call workspaced — see ResolveBrowserHost.
 // Name kept for compatibility; only Helium is supported as the app window host.
 func GetChromium() (string, error) {
+	return (&HeliumHost{}).findLocal()
+}
+
+// findLocal is GetChromium through h's PATH lookup.
+func (h *HeliumHost) findLocal() (string, error) {
 	for _, ch := range heliumCandidates {
-		path, err := lookPath(ch)
+		path, err := h.look(ch)
 		if errors.Is(err, exec.ErrNotFound) {
 			continue
 		}
@@ -56,8 +59,29 @@ func GetChromium() (string, error) {
 	return "", ErrNoChromium
 }
 
-// resolveBrowserHost is the host-resolve implementation; tests may override.
-var resolveBrowserHost = ResolveBrowserHost
+// ensureEnabled resolves Ensure, falling back to ELETROCROMO_NO_ENSURE.
+func (h *HeliumHost) ensureEnabled() bool {
+	if h.Ensure != nil {
+		return *h.Ensure
+	}
+	return !ensureDisabled()
+}
+
+func (h *HeliumHost) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return slog.Default()
+}
+
+// workspacedPath resolves WorkspacedPath, falling back to
+// ELETROCROMO_WORKSPACED.
+func (h *HeliumHost) workspacedPath() string {
+	if p := strings.TrimSpace(h.WorkspacedPath); p != "" {
+		return p
+	}
+	return workspacedPathOverride()
+}
 
 // ResolveBrowserHost finds Helium for --app launch.
 //
@@ -67,13 +91,19 @@ var resolveBrowserHost = ResolveBrowserHost
 //
 // Set ELETROCROMO_NO_ENSURE=1 to skip network ensure (tests/CI).
 func ResolveBrowserHost(ctx context.Context) (string, error) {
-	if path, err := GetChromium(); err == nil {
+	return (&HeliumHost{}).Resolve(ctx)
+}
+
+// resolve is ResolveBrowserHost with h's overrides.
+func (h *HeliumHost) resolve(ctx context.Context) (string, error) {
+	if path, err := h.findLocal(); err == nil {
 		return path, nil
 	}
-	if ensureDisabled() {
-		return "", fmt.Errorf("%w: install Helium, or allow ensure (unset ELETROCROMO_NO_ENSURE)", ErrNoChromium)
+	if !h.ensureEnabled() {
+		return "", fmt.Errorf("%w: install Helium, or allow ensure (WithEnsure(true) / unset ELETROCROMO_NO_ENSURE)", ErrNoChromium)
 	}
-	path, err := ensureHeliumBrowser(ctx)
+	h.logger().Info("Helium not found locally; ensuring via workspaced", "tool", heliumBrowserTool)
+	path, err := h.ensureHelium(ctx)
 	if err != nil {
 		return "", fmt.Errorf("%w: %w", ErrNoChromium, err)
 	}
@@ -90,12 +120,18 @@ func envTruthy(key string) bool {
 	return v == "1" || strings.EqualFold(v, "true") || strings.EqualFold(v, "yes")
 }
 
-// appWindow is a started Helium process with a single Wait owner.
-type appWindow struct {
+// heliumWindow is a started Helium process (HeliumHost's BrowserWindow).
+type heliumWindow struct {
 	cmd      *exec.Cmd
 	stderr   bytes.Buffer
 	stderrMu sync.Mutex
-	waitc    chan error // holds Wait result once (capacity 1 via newAppWindowWaitc)
+	devtools *DevTools // nil without a DevTools pipe
+}
+
+// appWindow is a launched BrowserWindow with a single Wait owner.
+type appWindow struct {
+	bw    BrowserWindow
+	waitc chan error // holds Wait result once (capacity 1 via newAppWindowWaitc)
 }
 
 // newAppWindowWaitc returns a 1-buffered Wait channel. Factored out so the
@@ -105,9 +141,13 @@ func newAppWindowWaitc() chan error {
 	return c
 }
 
-// startAppWindow starts Helium with an isolated user-data-dir and --app URL.
+// startAppWindow starts Helium with an isolated user-data-dir and --app URL;
+// extra (WindowOptions.flags) goes before --app.
 // On Unix the child is put in its own process group so stop() can kill the tree.
-func startAppWindow(bin, rawURL, userDataDir string) (*appWindow, error) {
+// With devTools (windows Run manages) it also gets a DevTools pipe (fds 3 and
+// 4, --remote-debugging-pipe) where supported; a window nobody drives must not
+// keep a debugging channel open.
+func startAppWindow(bin, rawURL, userDataDir string, devTools bool, extra ...string) (*heliumWindow, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, err
@@ -118,28 +158,99 @@ func startAppWindow(bin, rawURL, userDataDir string) (*appWindow, error) {
 	if userDataDir == "" {
 		return nil, ErrUserDataDirRequired
 	}
-	w := &appWindow{waitc: newAppWindowWaitc()}
+	w := &heliumWindow{}
 	// Chromium-family app window + dedicated profile so apps do not share
 	// cookies/sessions or steal each other's windows.
-	w.cmd = exec.Command(bin,
-		"--user-data-dir="+userDataDir,
+	args := []string{
+		"--user-data-dir=" + userDataDir,
 		"--no-first-run",
 		"--no-default-browser-check",
-		"--app="+u.String(),
-	)
+	}
+	args = append(args, extra...)
+	var pipe *devToolsPipe
+	if devTools && devToolsPipeSupported {
+		if pipe, err = newDevToolsPipe(); err != nil {
+			return nil, err
+		}
+		args = append(args, "--remote-debugging-pipe")
+	}
+	w.cmd = exec.Command(bin, append(args, "--app="+u.String())...)
 	putInOwnProcessGroup(w.cmd)
 	w.cmd.Stderr = &lockedWriter{mu: &w.stderrMu, w: &w.stderr}
 	// Drop stdout noise from Chromium; keep stderr for launch diagnostics.
 	w.cmd.Stdout = nil
-	if err := w.cmd.Start(); err != nil {
+	if pipe != nil {
+		w.cmd.ExtraFiles = []*os.File{pipe.childR, pipe.childW}
+	}
+	err = w.cmd.Start()
+	if pipe != nil {
+		pipe.closeChildEnds()
+	}
+	if err != nil {
+		if pipe != nil {
+			_ = pipe.r.Close()
+			_ = pipe.w.Close()
+		}
 		return nil, err
 	}
-	go func() {
-		w.waitc <- w.cmd.Wait()
-	}()
+	if pipe != nil {
+		w.devtools = newDevTools(pipe.r, pipe.w)
+	}
 	return w, nil
 }
 
+// Wait reaps the process and closes its DevTools pipe.
+func (w *heliumWindow) Wait() error {
+	err := w.cmd.Wait()
+	if w.devtools != nil {
+		w.devtools.close()
+	}
+	return err
+}
+
+// Stop kills the Helium process tree (process group on Unix) if still running.
+func (w *heliumWindow) Stop() {
+	killProcessTree(w.cmd)
+}
+
+func (w *heliumWindow) Pid() int { return w.cmd.Process.Pid }
+
+func (w *heliumWindow) DevTools() *DevTools { return w.devtools }
+
+func (w *heliumWindow) stderrSnapshot() string {
+	w.stderrMu.Lock()
+	defer w.stderrMu.Unlock()
+	return w.stderr.String()
+}
+
+// devToolsPipe is --remote-debugging-pipe: Helium reads commands on fd 3
+// (childR) and writes replies on fd 4 (childW); we keep the other ends.
+type devToolsPipe struct {
+	childR, w *os.File
+	r, childW *os.File
+}
+
+func newDevToolsPipe() (*devToolsPipe, error) {
+	childR, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	r, childW, err := os.Pipe()
+	if err != nil {
+		_ = childR.Close()
+		_ = w.Close()
+		return nil, err
+	}
+	return &devToolsPipe{childR: childR, w: w, r: r, childW: childW}, nil
+}
+
+// closeChildEnds drops our copies of the child's ends once it has them, so
+// its exit reads as EOF.
+func (p *devToolsPipe) closeChildEnds() {
+	_ = p.childR.Close()
+	_ = p.childW.Close()
+}
+
 type lockedWriter struct {
 	mu *sync.Mutex
 	w  *bytes.Buffer
@@ -151,6 +262,15 @@ func (l *lockedWriter) Write(p []byte) (int, error) {
 	return l.w.Write(p)
 }
 
+// newAppWindow takes over bw's Wait.
+func newAppWindow(bw BrowserWindow) *appWindow {
+	w := &appWindow{bw: bw, waitc: newAppWindowWaitc()}
+	go func() {
+		w.waitc <- bw.Wait()
+	}()
+	return w
+}
+
 // awaitStartup returns an error if Helium exits within grace (failed launch).
 // If still running after grace, returns nil; call watchExit to reap later.
 func (w *appWindow) awaitStartup(grace time.Duration) error {
@@ -161,7 +281,11 @@ func (w *appWindow) awaitStartup(grace time.Duration) error {
 	defer timer.Stop()
 	select {
 	case err := <-w.waitc:
-		return wrapHeliumExit(w.stderrSnapshot(), err)
+		var stderr string
+		if s, ok := w.bw.(interface{ stderrSnapshot() string }); ok {
+			stderr = s.stderrSnapshot()
+		}
+		return wrapHeliumExit(stderr, err)
 	case <-timer.C:
 		return nil
 	}
@@ -178,18 +302,28 @@ func (w *appWindow) watchExit(onExit func(error)) {
 	}()
 }
 
-// stop kills the Helium process tree (process group on Unix) if still running.
+// stop ends the window's process if still running.
 func (w *appWindow) stop() {
 	if w == nil {
 		return
 	}
-	killProcessTree(w.cmd)
+	w.bw.Stop()
 }
 
-func (w *appWindow) stderrSnapshot() string {
-	w.stderrMu.Lock()
-	defer w.stderrMu.Unlock()
-	return w.stderr.String()
+// pid is the window's process id, or 0 when the BrowserWindow has no Pid.
+func (w *appWindow) pid() int {
+	if p, ok := w.bw.(interface{ Pid() int }); ok {
+		return p.Pid()
+	}
+	return 0
+}
+
+// devTools is the window's DevTools client, or nil.
+func (w *appWindow) devTools() *DevTools {
+	if d, ok := w.bw.(interface{ DevTools() *DevTools }); ok {
+		return d.DevTools()
+	}
+	return nil
 }
 
 // redactSecretsInText strips URL query/fragment and bare token= values so
@@ -250,14 +384,16 @@ func LaunchChromium(ctx context.Context, u *url.URL, appID string) error {
 	if err != nil {
 		return err
 	}
-	bin, err := resolveBrowserHost(ctx)
+	bin, err := (&HeliumHost{}).Resolve(ctx)
 	if err != nil {
 		return err
 	}
-	w, err := startAppWindow(bin, u.String(), profileDir)
+	// Detached: nothing drives the window, so it gets no DevTools pipe.
+	hw, err := startAppWindow(bin, u.String(), profileDir, false)
 	if err != nil {
 		return err
 	}
+	w := newAppWindow(hw)
 	if err := w.awaitStartup(heliumStartupGrace); err != nil {
 		w.stop()
 		return err
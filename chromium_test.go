@@ -10,7 +10,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -95,10 +94,7 @@ func TestGetChromium_NoPanic(t *testing.T) {
 }
 
 func TestGetChromium_OnlyHelium(t *testing.T) {
-	orig := lookPath
-	t.Cleanup(func() { lookPath = orig })
-
-	lookPath = func(file string) (string, error) {
+	h := &HeliumHost{lookPath: func(file string) (string, error) {
 		switch file {
 		case "helium":
 			return "/fake/helium", nil
@@ -107,8 +103,8 @@ func TestGetChromium_OnlyHelium(t *testing.T) {
 		default:
 			return "", exec.ErrNotFound
 		}
-	}
-	path, err := GetChromium()
+	}}
+	path, err := h.findLocal()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -118,28 +114,22 @@ func TestGetChromium_OnlyHelium(t *testing.T) {
 }
 
 func TestGetChromium_IgnoresOtherBrowsers(t *testing.T) {
-	orig := lookPath
-	t.Cleanup(func() { lookPath = orig })
-
-	lookPath = func(file string) (string, error) {
+	h := &HeliumHost{lookPath: func(file string) (string, error) {
 		if file == "chromium" || file == "chrome" || file == "google-chrome" {
 			return "/usr/bin/" + file, nil
 		}
 		return "", exec.ErrNotFound
-	}
-	_, err := GetChromium()
+	}}
+	_, err := h.findLocal()
 	if !errors.Is(err, ErrNoChromium) {
 		t.Fatalf("want ErrNoChromium when only Chrome/Chromium present, got path/err %v", err)
 	}
 }
 
 func TestResolveBrowserHost_NoEnsureNoHost(t *testing.T) {
-	orig := lookPath
-	t.Cleanup(func() { lookPath = orig })
-	lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
-
 	t.Setenv("ELETROCROMO_NO_ENSURE", "1")
-	_, err := ResolveBrowserHost(t.Context())
+	h := &HeliumHost{lookPath: func(string) (string, error) { return "", exec.ErrNotFound }}
+	_, err := h.Resolve(t.Context())
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -153,29 +143,23 @@ func TestResolveBrowserHost_NoEnsureNoHost(t *testing.T) {
 }
 
 func TestResolveBrowserHost_EnsureViaWorkspacedWhich(t *testing.T) {
-	origLook := lookPath
-	origCmd := commandOutput
-	t.Cleanup(func() {
-		lookPath = origLook
-		commandOutput = origCmd
-	})
-
-	lookPath = func(file string) (string, error) {
-		if file == "workspaced" {
-			return "/bin/workspaced", nil
-		}
-		return "", exec.ErrNotFound
-	}
 	t.Setenv("ELETROCROMO_NO_ENSURE", "")
 	t.Setenv("ELETROCROMO_WORKSPACED", "")
 
 	var gotArgs []string
-	commandOutput = func(ctx context.Context, name string, args ...string) ([]byte, error) {
-		gotArgs = append([]string{name}, args...)
-		return []byte("/cache/tools/helium\n"), nil
+	h := &HeliumHost{
+		lookPath: func(file string) (string, error) {
+			if file == "workspaced" {
+				return "/bin/workspaced", nil
+			}
+			return "", exec.ErrNotFound
+		},
+		commandOutput: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			gotArgs = append([]string{name}, args...)
+			return []byte("/cache/tools/helium\n"), nil
+		},
 	}
-
-	path, err := ResolveBrowserHost(t.Context())
+	path, err := h.Resolve(t.Context())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -194,9 +178,7 @@ func TestResolveBrowserHost_EnsureViaWorkspacedWhich(t *testing.T) {
 }
 
 func TestLaunchChromium_NoSystemBrowserFallback(t *testing.T) {
-	orig := lookPath
-	t.Cleanup(func() { lookPath = orig })
-	lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+	t.Setenv("PATH", t.TempDir()) // no helium (nor any other browser)
 	t.Setenv("ELETROCROMO_NO_ENSURE", "1")
 	t.Setenv("XDG_DATA_HOME", t.TempDir())
 
@@ -241,18 +223,13 @@ func TestRun_RequiresAppID(t *testing.T) {
 }
 
 func TestRun_ResolveFailsBeforeServer(t *testing.T) {
-	orig := resolveBrowserHost
-	t.Cleanup(func() { resolveBrowserHost = orig })
 	t.Setenv("XDG_DATA_HOME", t.TempDir())
 
-	resolveBrowserHost = func(context.Context) (string, error) {
-		return "", fmt.Errorf("%w: test deny", ErrNoChromium)
-	}
-
 	app := App{
-		ID:      "br.tec.lew.test.resolve",
-		Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
-		Context: t.Context(),
+		ID:          "br.tec.lew.test.resolve",
+		Handler:     http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		Context:     t.Context(),
+		BrowserHost: &fakeHost{err: fmt.Errorf("%w: test deny", ErrNoChromium)},
 	}
 	err := app.Run()
 	if err == nil {
@@ -264,12 +241,8 @@ func TestRun_ResolveFailsBeforeServer(t *testing.T) {
 }
 
 func TestRun_ImmediateHeliumExitIsError(t *testing.T) {
-	origResolve := resolveBrowserHost
 	origGrace := heliumStartupGrace
-	t.Cleanup(func() {
-		resolveBrowserHost = origResolve
-		heliumStartupGrace = origGrace
-	})
+	t.Cleanup(func() { heliumStartupGrace = origGrace })
 	t.Setenv("XDG_DATA_HOME", t.TempDir())
 	heliumStartupGrace = 200 * time.Millisecond
 
@@ -277,14 +250,12 @@ func TestRun_ImmediateHeliumExitIsError(t *testing.T) {
 	if err != nil {
 		t.Skip("no true binary")
 	}
-	resolveBrowserHost = func(context.Context) (string, error) {
-		return trueBin, nil
-	}
 
 	app := App{
-		ID:      "br.tec.lew.test.exit",
-		Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
-		Context: t.Context(),
+		ID:          "br.tec.lew.test.exit",
+		Handler:     http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		Context:     t.Context(),
+		BrowserHost: &HeliumHost{Path: trueBin},
 	}
 	err = app.Run()
 	if err == nil {
@@ -296,40 +267,26 @@ func TestRun_ImmediateHeliumExitIsError(t *testing.T) {
 }
 
 func TestRun_ResolvesThenLaunches(t *testing.T) {
-	origResolve := resolveBrowserHost
-	origGrace := heliumStartupGrace
-	t.Cleanup(func() {
-		resolveBrowserHost = origResolve
-		heliumStartupGrace = origGrace
-	})
-	t.Setenv("XDG_DATA_HOME", t.TempDir())
-	heliumStartupGrace = 100 * time.Millisecond
-
-	var resolved atomic.Bool
-	script := filepath.Join(t.TempDir(), "fake-helium")
-	// Stay up longer than grace + parent timeout window.
-	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
-		t.Fatal(err)
-	}
-	resolveBrowserHost = func(context.Context) (string, error) {
-		resolved.Store(true)
-		return script, nil
-	}
+	fakeHostEnv(t)
+	host := &fakeHost{bin: "/opt/helium"}
 
 	// Bound Run by context instead of a manual Sleep goroutine.
 	ctx, cancel := context.WithTimeout(t.Context(), 400*time.Millisecond)
 	defer cancel()
 
 	app := App{
-		ID:      "br.tec.lew.test.launch",
-		Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
-		Context: ctx,
+		ID:          "br.tec.lew.test.launch",
+		Handler:     http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		Context:     ctx,
+		BrowserHost: host,
 	}
 	if err := app.Run(); err != nil {
 		t.Fatal(err)
 	}
-	if !resolved.Load() {
-		t.Fatal("resolveBrowserHost was not called")
+	host.mu.Lock()
+	defer host.mu.Unlock()
+	if host.resolved != 1 || len(host.launches) != 1 || host.launchBin != "/opt/helium" {
+		t.Fatalf("resolved %d times, launched %d times with %q", host.resolved, len(host.launches), host.launchBin)
 	}
 }
 
@@ -361,14 +318,11 @@ func TestBootstrapSkipsDownloadWhenCached(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	origGet := httpGet
-	t.Cleanup(func() { httpGet = origGet })
-	httpGet = func(context.Context, string) (*http.Response, error) {
+	h := &HeliumHost{httpGet: func(context.Context, string) (*http.Response, error) {
 		t.Fatal("httpGet should not be called when binary is cached")
 		return nil, nil
-	}
-
-	path, err := bootstrapWorkspaced(t.Context())
+	}}
+	path, err := h.bootstrapWorkspaced(t.Context())
 	if err != nil {
 		t.Fatal(err)
 	}
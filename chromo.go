@@ -2,17 +2,17 @@ package eletrocromo
 
 import (
 	"context"
-	"crypto/subtle"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
-	"net/http/httptest"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -26,81 +26,265 @@ type App struct {
 	// APK package name when packaging is added later.
 	ID string
 
-	Handler   http.Handler
+	Handler http.Handler
+	// Server optionally supplies the *http.Server used by Run (timeouts,
+	// MaxHeaderBytes, ErrorLog, ConnState, TLS, …). Addr must be empty: the
+	// library owns the loopback bind. See NewServer.
+	Server    *http.Server
 	AuthToken string
 	WaitGroup sync.WaitGroup
 	Context   context.Context
 
 	// NoUI skips Helium resolve/launch and only serves loopback HTTP.
 	// Used by the Android WebView host (and tests). Also enabled when
-	// ELETROCROMO_NO_UI is 1/true/yes.
+	// ELETROCROMO_NO_UI is 1/true/yes; WithNoUI overrides both.
 	NoUI bool
+
+	// WorkspacedPath pins the workspaced binary used to ensure Helium.
+	// Wins over ELETROCROMO_WORKSPACED; empty means env, then PATH/bootstrap.
+	WorkspacedPath string
+
+	// BrowserHost resolves and launches the window's browser. Nil means a
+	// HeliumHost using WorkspacedPath and WithEnsure; a custom host ignores
+	// both.
+	BrowserHost BrowserHost
+
+	// Background keeps the process (server + BackgroundRun tasks) alive after
+	// the Helium window closes; reopen it with OpenWindow. Also enabled by
+	// ELETROCROMO_BACKGROUND=1 or the -background flag (BindFlags);
+	// WithBackground overrides all of them. Default is window-owned: closing
+	// the window cancels Run.
+	Background bool
+
+	// Tray shows a system tray icon with Open (relaunch the window) and Quit
+	// (cancel Run), plus TrayItems. Always on in Background mode. Linux only:
+	// StatusNotifierItem + DBusMenu over the session bus, no CGo.
+	Tray bool
+	// TrayTitle is the tray tooltip/title; defaults to App.ID.
+	TrayTitle string
+	// TrayIcon is a freedesktop icon-theme name; defaults to applications-internet.
+	TrayIcon string
+	// TrayItems are app menu entries shown between Open and Quit.
+	TrayItems []TrayItem
+
+	// Listener, when set, is served instead of binding; it must be a TCP
+	// listener on a loopback address (ErrNonLoopback). Run does not close it
+	// until shutdown.
+	Listener net.Listener
+	// SecurityHeaders overrides the baseline per header name; an empty value
+	// disables that header. "{nonce}" is replaced per request. Baseline:
+	// Content-Security-Policy (default-src 'self', nonce-only inline
+	// script/style, frame-ancestors 'none'), X-Content-Type-Options: nosniff,
+	// Referrer-Policy: no-referrer, Cross-Origin-Opener-Policy: same-origin.
+	SecurityHeaders map[string]string
+
+	// OnUnauthorized, when set, renders 401 responses (e.g. a "reopen from
+	// the app" page); the status is 401 unless it writes another.
+	OnUnauthorized http.Handler
+	// OnAuthEvent is called for every refused request. It runs on the
+	// request goroutine: keep it fast and safe for concurrent use.
+	OnAuthEvent func(AuthEvent)
+	// MaxAuthFailures invalid credentials from one source within AuthLockout
+	// lock it out for AuthLockout: its further invalid credentials get 429,
+	// valid ones are still accepted. Zero means DefaultMaxAuthFailures;
+	// negative disables throttling.
+	MaxAuthFailures int
+	// AuthLockout is the throttling window and lockout; zero means
+	// DefaultAuthLockout.
+	AuthLockout time.Duration
+
+	// SessionTTL is the lifetime of the signed session cookie issued on
+	// bootstrap (renewed once half spent). Zero means DefaultSessionTTL.
+	SessionTTL time.Duration
+
+	// AllowedHosts are extra Host header values the gate accepts besides the
+	// bound 127.0.0.1:PORT, e.g. "myapp.localhost" (any port) or "host:port".
+	AllowedHosts []string
+	// Port binds 127.0.0.1:Port or fails Run (ErrPortInUse).
+	Port int
+	// PreferredPort is tried first; if taken an ephemeral port is used.
+	PreferredPort int
+	// PersistPort remembers the bound port per App.ID and reuses it on the
+	// next Run, so the browser origin (and its storage) stays stable.
+	PersistPort bool
+
+	// MultiInstance disables the per-ID single-instance lock. By default a
+	// second desktop Run of the same App.ID forwards its args to the running
+	// instance, which focuses or reopens its window, and returns nil.
+	// Unix only: on Windows every run acts as the primary.
+	MultiInstance bool
+	// OnResume is called in the running instance when a second launch is
+	// forwarded to it (before the window is focused or reopened).
+	OnResume func(ResumeRequest)
+
+	// Lifecycle hooks. They run synchronously on Run's goroutines, so keep
+	// them short; none is called concurrently with itself.
+	//
+	// OnReady is called once the server accepts requests, with its base URL
+	// (no credentials), before the window opens.
+	OnReady func(url string)
+	// OnWindowOpened is called after each Helium launch survives startup,
+	// with its process id (0 when a custom BrowserHost's window has no Pid).
+	OnWindowOpened func(pid int)
+	// OnWindowClosed is called when that window's process exits: nil for a
+	// normal exit or one caused by shutdown, else the exit error.
+	OnWindowClosed func(err error)
+	// OnTargetEvent is called for DevTools target changes in the window:
+	// pages and workers appearing, navigating and going away (e.g. the user
+	// followed a link off the app). Calls are in order, on their own
+	// goroutine; see App.DevTools.
+	OnTargetEvent func(TargetEvent)
+	// OnShutdown is called when shutdown starts and again right before Run
+	// returns.
+	OnShutdown func(ShutdownPhase)
+
+	// ShutdownTimeout bounds the HTTP drain after cancel: in-flight requests
+	// may finish, then remaining connections are closed. Zero means
+	// DefaultShutdownTimeout; negative waits indefinitely.
+	ShutdownTimeout time.Duration
+	// TaskTimeout bounds the wait for BackgroundRun tasks after cancel; past
+	// it Run returns ErrTasksDidNotStop naming them. Zero means
+	// DefaultTaskTimeout; negative waits indefinitely.
+	TaskTimeout time.Duration
+	// TaskStart says when tasks passed to BackgroundRun before Run (or while
+	// it starts up) start: once the server is ready (the default), once the
+	// window opened, or as soon as the server is bound.
+	TaskStart TaskStartPolicy
+
+	// Window shapes the Helium window: size, position, scale, kiosk, dark
+	// mode and extra flags (see WindowOptions).
+	Window WindowOptions
+
+	// Logger receives Run's records (slog.Default when nil), each with
+	// app_id, pid and phase attributes. Records are scrubbed first: URL
+	// queries, token= pairs and AuthToken never reach the handler.
+	Logger *slog.Logger
+
+	// ensure overrides ELETROCROMO_NO_ENSURE when non-nil (see WithEnsure).
+	ensure *bool
+	// noUI and background override App.NoUI / App.Background and their env
+	// vars when non-nil (see WithNoUI, WithBackground).
+	noUI       *bool
+	background *bool
+
+	mu               sync.Mutex
+	run              *runState              // non-nil while Run is active
+	boundHost        string                 // host:port served by Run; gates the Host header
+	scoped           map[string]scopedToken // MintToken tokens by name
+	sessions         *sessionStore          // signed session cookies; nil outside Run
+	throttle         authThrottle
+	bootstrap        map[[sha256.Size]byte]time.Time // live bootstrap tokens (hashed) → expiry
+	shutdownHooks    []func()
+	tasks            map[uint64]*taskEntry // BackgroundRun tasks (see Tasks)
+	taskSeq          uint64
+	queuedTasks      []queuedTask       // BackgroundRun before Run, waiting for it
+	runStarting      bool               // Run is active and has not started queuedTasks yet
+	ran              bool               // a Run has begun; later BackgroundRun calls need not wait
+	taskErrs         []*TaskError       // failures since the last Run returned
+	reportedTaskErrs int                // leading taskErrs the last Run returned; the next Run drops them
+	cancelRun        context.CancelFunc // non-nil while Run is active
 }
 
 // ReadyLinePrefix is printed once the loopback server is listening in NoUI mode.
-// The Android shell parses the following URL (token included) to open WebView.
+// The Android shell parses the following URL to open WebView; its token is a
+// single-use bootstrap token (exchanged for the session cookie).
 //
 //	ELETROCROMO_READY http://127.0.0.1:PORT/?token=UUID
 const ReadyLinePrefix = "ELETROCROMO_READY "
 
 const AUTH_COOKIE_KEY = "eletrocromo_token"
 
+// ErrServerAddr is returned by Run when App.Server.Addr is set: the library
+// assigns the loopback address/port, never the app.
+var ErrServerAddr = errors.New("http.Server.Addr must be empty (eletrocromo owns the loopback bind)")
+
 // background is the default when App.Context is nil (same for Run and BackgroundRun).
 // Package-level so methods do not call context.Background directly.
 var background = context.Background()
 
 // BackgroundRun starts task in a new goroutine and tracks it on WaitGroup.
-// It returns immediately after scheduling; task errors are logged.
+// It returns immediately after scheduling; task errors (and panics, as
+// ErrTaskPanic) are logged and kept in TaskErrors. Wrap task with Supervise
+// to restart it, and with Critical to fail Run when it fails.
+//
+// Before the first Run, and while Run is starting up, the task is queued:
+// Run starts it on its own context at the point App.TaskStart picks, so it
+// stops together with the window. Otherwise it starts at once on
+// App.Context (Run's own context while Run is active; context.Background()
+// when nil). A Run that returns before starting the queue (another instance
+// is running, the port is taken) leaves it for the next Run.
 // Callers must not wrap BackgroundRun in another goroutine — Add runs
 // synchronously so WaitGroup.Wait is race-free with respect to this call.
-// A nil App.Context is treated as context.Background(), matching Run.
 func (a *App) BackgroundRun(task Task) error {
+	a.mu.Lock()
+	if a.runStarting || !a.ran {
+		a.queueTask(task)
+		a.mu.Unlock()
+		return nil
+	}
+	entry := a.newTaskEntry(taskName(task), isCritical(task), TaskRunning)
+	a.mu.Unlock()
 	ctx := a.Context
 	if ctx == nil {
 		ctx = background
 	}
+	a.startTask(ctx, task, entry)
+	return nil
+}
+
+// startTask runs a registered task on ctx, tracked on WaitGroup.
+func (a *App) startTask(ctx context.Context, task Task, entry *taskEntry) {
+	lg := a.logger(phaseTask)
 	a.WaitGroup.Add(1)
+	ctx = context.WithValue(ctx, taskLoggerKey{}, lg)
+	ctx = context.WithValue(ctx, taskEntryKey{}, taskHandle{a: a, e: entry})
 	go func() {
 		defer a.WaitGroup.Done()
-		if err := task.Run(ctx); err != nil {
-			log.Printf("background task: %v", err)
+		var te *TaskError
+		defer func() { a.finishTask(entry, te) }()
+		if err := runTask(ctx, task); err != nil {
+			if te = a.recordTaskError(ctx, task, err); te != nil {
+				lg.Error("background task failed", "task", te.Task, "critical", te.Critical, "err", err)
+			}
 		}
 	}()
-	return nil
 }
 
 // ServeHTTP handles incoming HTTP requests with authentication enforcement.
 //
 // Authentication Flow:
-// 1. Checks for an authentication token in the URL query parameters (used for the initial handshake).
-// 2. If present and valid, sets a strict, HttpOnly cookie with the token.
-// 3. If no query token is present, falls back to checking the cookie.
+//  1. Checks for an authentication token in the URL query parameters (used for the initial handshake).
+//  2. A single-use bootstrap token (what Run puts in launch URLs) sets a strict,
+//     HttpOnly cookie and 303-redirects GET/HEAD to the same URL without it.
+//  3. AuthToken itself in the query also sets the cookie (hand-built links).
+//  4. If no query token is present, falls back to checking the cookie.
 //
 // Security Policy:
-// - Fail Closed: If the token is invalid or missing, returns 401 Unauthorized.
-// - If no internal Handler is configured, returns 404 Not Found.
+//   - Before auth: during Run, a Host other than the bound address (or
+//     AllowedHosts) and cross-site unsafe requests (Origin/Sec-Fetch-Site) get
+//     403 Forbidden (DNS rebinding, CSRF).
+//   - Fail Closed: If the token is invalid or missing, returns 401 Unauthorized.
+//   - If no internal Handler is configured, returns 404 Not Found.
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-	if token != "" {
-		if subtle.ConstantTimeCompare([]byte(token), []byte(a.AuthToken)) == 1 {
-			http.SetCookie(w, &http.Cookie{
-				Name:     AUTH_COOKIE_KEY,
-				Value:    token,
-				Path:     "/",
-				HttpOnly: true,
-				SameSite: http.SameSiteStrictMode,
-			})
-		}
-	} else if cookie, err := r.Cookie(AUTH_COOKIE_KEY); err == nil {
-		token = cookie.Value
-	}
-	// Fail closed when AuthToken is unset: ConstantTimeCompare("", "") would
-	// otherwise accept unauthenticated requests (ServeHTTP without Run).
-	if a.AuthToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.AuthToken)) != 1 {
-		w.WriteHeader(http.StatusUnauthorized)
-		if _, err := io.WriteString(w, "forbidden"); err != nil {
-			return
-		}
+	if reason := a.guardRequest(r); reason != "" {
+		a.refuse(w, r, reason)
+		return
+	}
+	info, reason, handled := a.authenticate(w, r)
+	if handled {
+		return
+	}
+	if reason == "" && !allowedByScope(info.Scope, r) {
+		reason = AuthInsufficientScope
+	}
+	if reason != "" {
+		a.refuse(w, r, reason)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), authInfoKey{}, info))
+	r = a.applySecurityHeaders(w, r)
+	if strings.HasPrefix(r.URL.Path, ReservedPathPrefix) {
+		a.serveReserved(w, r)
 		return
 	}
 	if a.Handler == nil {
@@ -113,17 +297,35 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.Handler.ServeHTTP(w, r)
 }
 
+func (a *App) setAuthCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AUTH_COOKIE_KEY,
+		Value:    a.AuthToken,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
 // Run starts the application and blocks until the context is cancelled.
 //
 // Startup Sequence (desktop):
-//  1. Validates App.ID (reverse-domain) and prepares an isolated Helium profile.
+//  1. Validates App.ID (reverse-domain) and takes the per-ID instance lock; if
+//     another process holds it, forwards args/cwd there and returns nil.
+//     Then prepares an isolated Helium profile.
 //  2. Generates a new random AuthToken if one is not already set.
-//  3. Resolves Helium (local PATH or workspaced ensure) — before binding any port.
-//  4. Starts the internal HTTP server (httptest for ephemeral loopback bind).
+//  3. Resolves Helium (local PATH or workspaced ensure, or App.BrowserHost) —
+//     before binding any port.
+//  4. Binds loopback (App.Listener, Port, PreferredPort, PersistPort, else an
+//     ephemeral 127.0.0.1 port) and serves, using App.Server's configuration
+//     when set (see NewServer).
 //  5. Launches Helium with --user-data-dir + --app; fails Run if the process
 //     exits during a short startup grace (launch failures are not ignored).
-//  6. Blocks until the context is cancelled (including Helium exit), then waits
-//     for background tasks and shuts down the server.
+//  6. Blocks until the context is cancelled, then drains the server
+//     (ShutdownTimeout, RegisterOnShutdown hooks) and waits for background
+//     tasks (TaskTimeout, ErrTasksDidNotStop). Helium exit cancels the context unless
+//     Background is set, in which case the app keeps serving and OpenWindow
+//     relaunches the window.
 //
 // NoUI / ELETROCROMO_NO_UI: skip Helium; bind, print ReadyLinePrefix + URL, wait.
 func (a *App) Run() error {
@@ -133,6 +335,9 @@ func (a *App) Run() error {
 	if err := ValidateAppID(a.ID); err != nil {
 		return err
 	}
+	if a.Server != nil && a.Server.Addr != "" {
+		return fmt.Errorf("%w: got %q", ErrServerAddr, a.Server.Addr)
+	}
 
 	if a.AuthToken == "" {
 		a.AuthToken = uuid.New().String()
@@ -148,11 +353,49 @@ func (a *App) Run() error {
 	prevCtx := a.Context
 	a.Context = ctx
 	defer func() { a.Context = prevCtx }()
+	defer a.startRunTasks(cancel)()
 
-	noUI := a.NoUI || noUIEnabled()
+	noUI := a.noUIMode()
+	var windowFlags []string
+	if !noUI {
+		// A denied flag is a programming error: fail before taking the
+		// instance lock or resolving Helium.
+		var err error
+		if windowFlags, err = a.Window.flags(); err != nil {
+			return err
+		}
+	}
+
+	if !noUI && !a.MultiInstance {
+		inst, err := acquireInstance(a.ID)
+		if errors.Is(err, ErrAlreadyRunning) {
+			pid, err := resumeInstance(ctx, a.ID, currentResumeRequest())
+			if errors.Is(err, ErrAlreadyRunning) {
+				return err
+			}
+			lg := a.logger(phaseInstance)
+			if err != nil {
+				lg.Warn("resume failed", "err", err)
+			}
+			lg.Info("already running; forwarded to it", "primary_pid", pid)
+			return nil
+		}
+		switch {
+		case errors.Is(err, errInstanceUnsupported):
+			// No lock here (Windows): every run acts as the primary.
+			a.logger(phaseInstance).Debug("single-instance not supported on this platform")
+		case err != nil:
+			return err
+		default:
+			// Held until Run returns; the kernel releases it if we crash.
+			defer inst.close()
+			inst.serve(a.handleResume)
+		}
+	}
 
 	var profileDir string
 	var bin string
+	var host BrowserHost
 	if !noUI {
 		var err error
 		profileDir, err = ProfileDir(a.ID)
@@ -162,84 +405,186 @@ func (a *App) Run() error {
 		// Resolve Helium first: ensure can take a long time (download workspaced +
 		// helium-browser). Do not open a listening server until we know we can
 		// open a window; failures must not leave a loopback port up with a token.
-		log.Printf("resolving Helium host…")
-		bin, err = resolveBrowserHost(ctx)
+		lg := a.logger(phaseResolve)
+		lg.Info("resolving Helium host")
+		host = a.browserHost()
+		bin, err = host.Resolve(ctx)
 		if err != nil {
 			return err
 		}
-		log.Printf("Helium host: %s (profile %s)", bin, profileDir)
+		lg.Info("Helium host resolved", "bin", bin, "profile", profileDir)
 	}
 
-	ts := httptest.NewUnstartedServer(a)
-	ts.Config.BaseContext = func(_ net.Listener) context.Context {
-		return ctx
+	sessions, err := openSessionStore(a.ID, a.SessionTTL)
+	if err != nil {
+		return err
 	}
+	a.setSessionStore(sessions)
+	defer a.setSessionStore(nil)
 
-	started := make(chan struct{})
+	ln, err := a.listen()
+	if err != nil {
+		return err
+	}
+	// From here every return goes through stop, which waits for the tasks.
+	if a.TaskStart == TaskStartImmediately {
+		a.startQueuedTasks(ctx)
+	}
+	if a.Server != nil && a.Handler == nil {
+		a.Handler = a.Server.Handler
+	}
+	// Serve a copy: a shut down http.Server cannot serve again, and Serve
+	// fills in TLSConfig, so the caller's server stays as it was for the
+	// next Run.
+	srv := serverFor(a.Server)
+	srv.Handler = a
+	// Requests get their own context (with ctx's values) so cancelling Run
+	// drains them instead of aborting them; shutdownServer cancels it once
+	// the drain finishes or times out.
+	reqCtx, cancelRequests := context.WithCancel(context.WithoutCancel(ctx))
+	srv.BaseContext = requestBase(srv.BaseContext, reqCtx)
+	useTLS := srv.TLSConfig != nil
+	served := make(chan error, 1)
 	go func() {
-		ts.Start()
-		close(started)
-		<-ctx.Done()
-		ts.Close()
+		var err error
+		if useTLS {
+			// Certificates come from TLSConfig; no cert/key files.
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		if err != nil {
+			a.logger(phaseServe).Error("webserver failed", "err", err)
+			cancel()
+		}
+		served <- err
 	}()
-
-	<-started
-	// Prefer 127.0.0.1 host for Android WebView + networkSecurityConfig (not [::1]).
-	base := ts.URL
-	if u, err := url.Parse(ts.URL); err == nil {
-		host := u.Hostname()
-		if host == "" || host == "localhost" || host == "::1" {
-			u.Host = net.JoinHostPort("127.0.0.1", u.Port())
-			base = u.String()
+	var rs *runState
+	stop := func() error {
+		a.emitShutdown(ShutdownStarted)
+		cancel()
+		if rs != nil {
+			// Tear down the process group so helpers do not leak.
+			rs.stopWindow()
 		}
+		a.shutdownServer(srv, cancelRequests)
+		serveErr := <-served
+		waitErr := a.waitTasks()
+		err := errors.Join(serveErr, a.runTaskErr(), waitErr)
+		a.emitShutdown(ShutdownCompleted)
+		return err
+	}
+
+	base := listenerBase(ln, useTLS)
+	a.setBoundHost(ln.Addr().String())
+	defer a.setBoundHost("")
+	// Launch URLs carry a fresh single-use bootstrap token, never AuthToken
+	// (argv is world-readable via /proc, and URLs land in history).
+	mintLink := func() string {
+		return fmt.Sprintf("%s/?token=%s", base, a.mintBootstrap())
 	}
-	link := fmt.Sprintf("%s/?token=%s", strings.TrimRight(base, "/"), a.AuthToken)
-	log.Printf("webserver started on %s", link)
+	a.logger(phaseServe).Info("webserver started", "url", base)
+	if noUI || a.TaskStart != TaskStartOnWindow {
+		// NoUI has no window to wait for.
+		a.startQueuedTasks(ctx)
+	}
+	a.emitReady(base)
 
 	if noUI {
 		// Machine-parseable line on stdout without log timestamps (Android host).
-		// Also log for humans / tests that capture log.Writer().
+		link := mintLink()
+		// The link itself never goes to the logger.
 		fmt.Fprintln(os.Stdout, ReadyLinePrefix+link)
-		log.Print(ReadyLinePrefix + link)
 		// Optional side channel: write the URL to a file (stdout can block or be
 		// lost under ProcessBuilder; Android shell sets ELETROCROMO_READY_FILE).
 		if path := strings.TrimSpace(os.Getenv("ELETROCROMO_READY_FILE")); path != "" {
 			if err := os.WriteFile(path, []byte(link+"\n"), 0o600); err != nil {
-				log.Printf("ELETROCROMO_READY_FILE: %v", err)
+				a.logger(phaseServe).Warn("ELETROCROMO_READY_FILE not written", "err", err)
 			}
 		}
+		a.setRunState(&runState{mintLink: mintLink, cancel: cancel})
+		defer a.setRunState(nil)
 		<-ctx.Done()
-		a.WaitGroup.Wait()
-		return nil
+		return stop()
 	}
 
-	win, err := startAppWindow(bin, link, profileDir)
-	if err != nil {
-		cancel()
-		a.WaitGroup.Wait()
-		return fmt.Errorf("launch Helium: %w", err)
+	rs = &runState{
+		ctx:        ctx,
+		host:       host,
+		bin:        bin,
+		profileDir: profileDir,
+		mintLink:   mintLink,
+		background: a.backgroundMode(),
+		cancel:     cancel,
+		onOpened:   a.OnWindowOpened,
+		onClosed:   a.OnWindowClosed,
+		onTarget:   a.OnTargetEvent,
+		flags:      windowFlags,
+		window:     a.Window,
+		boundsFile: a.windowBoundsFile(),
+		log:        a.logger(phaseWindow),
 	}
-	if err := win.awaitStartup(heliumStartupGrace); err != nil {
-		win.stop()
-		cancel()
-		a.WaitGroup.Wait()
-		return err
+	if err := rs.openWindow(); err != nil {
+		return errors.Join(err, stop())
+	}
+	a.startQueuedTasks(ctx)
+	a.setRunState(rs)
+	if rs.background || a.Tray {
+		a.startAppTray(ctx, rs)
 	}
-	// After a healthy start, Helium exit cancels the app (window-owned lite).
-	win.watchExit(func(exitErr error) {
-		if exitErr != nil {
-			log.Printf("Helium exited: %v", exitErr)
-		} else {
-			log.Printf("Helium exited")
-		}
-		cancel()
-	})
 
 	<-ctx.Done()
-	// Ctrl+C / parent cancel: tear down the process group so helpers do not leak.
-	win.stop()
-	a.WaitGroup.Wait()
-	return nil
+	a.setRunState(nil)
+	return stop()
+}
+
+// serverFor is the server one Run serves with: a fresh http.Server with the
+// caller's settings (TLSConfig cloned), or a zero one when caller is nil.
+func serverFor(caller *http.Server) *http.Server {
+	if caller == nil {
+		return &http.Server{}
+	}
+	return &http.Server{
+		Handler:                      caller.Handler,
+		DisableGeneralOptionsHandler: caller.DisableGeneralOptionsHandler,
+		TLSConfig:                    caller.TLSConfig.Clone(),
+		ReadTimeout:                  caller.ReadTimeout,
+		ReadHeaderTimeout:            caller.ReadHeaderTimeout,
+		WriteTimeout:                 caller.WriteTimeout,
+		IdleTimeout:                  caller.IdleTimeout,
+		MaxHeaderBytes:               caller.MaxHeaderBytes,
+		TLSNextProto:                 caller.TLSNextProto,
+		ConnState:                    caller.ConnState,
+		ErrorLog:                     caller.ErrorLog,
+		BaseContext:                  caller.BaseContext,
+		ConnContext:                  caller.ConnContext,
+		HTTP2:                        caller.HTTP2,
+		Protocols:                    caller.Protocols,
+	}
+}
+
+// requestBase is the BaseContext Run serves with: reqCtx, or the caller's
+// BaseContext (its values) cancelled together with reqCtx.
+func requestBase(callerBase func(net.Listener) context.Context, reqCtx context.Context) func(net.Listener) context.Context {
+	if callerBase == nil {
+		return func(net.Listener) context.Context { return reqCtx }
+	}
+	return func(l net.Listener) context.Context {
+		base, cancel := context.WithCancel(callerBase(l))
+		context.AfterFunc(reqCtx, cancel)
+		return base
+	}
+}
+
+// noUIMode resolves NoUI: WithNoUI, then App.NoUI, then ELETROCROMO_NO_UI.
+func (a *App) noUIMode() bool {
+	if a.noUI != nil {
+		return *a.noUI
+	}
+	return a.NoUI || noUIEnabled()
 }
 
 func noUIEnabled() bool {
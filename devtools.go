@@ -0,0 +1,520 @@
+package eletrocromo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrDevToolsUnavailable is returned by App.DevTools when no window is open
+// or it was launched without a DevTools pipe (Windows, or a BrowserHost whose
+// windows have no DevTools method).
+var ErrDevToolsUnavailable = errors.New("window DevTools not available")
+
+// ErrDevToolsClosed is returned by DevTools calls once the window is gone.
+var ErrDevToolsClosed = errors.New("window DevTools connection closed")
+
+// ErrDevToolsCall wraps an error reply from the browser.
+var ErrDevToolsCall = errors.New("DevTools call failed")
+
+// ErrScriptException is returned by Evaluate when the script throws (or
+// its promise rejects).
+var ErrScriptException = errors.New("script threw an exception")
+
+// ErrNoPageTarget is returned when the window has no page to act on.
+var ErrNoPageTarget = errors.New("window has no page target")
+
+// WindowState is a window's show state.
+type WindowState string
+
+const (
+	WindowNormal     WindowState = "normal"
+	WindowMinimized  WindowState = "minimized"
+	WindowMaximized  WindowState = "maximized"
+	WindowFullscreen WindowState = "fullscreen"
+)
+
+// WindowBounds is the window's position and size in DIPs. Left, Top,
+// Width and Height describe the normal (restored) window.
+type WindowBounds struct {
+	Left   int         `json:"left,omitempty"`
+	Top    int         `json:"top,omitempty"`
+	Width  int         `json:"width,omitempty"`
+	Height int         `json:"height,omitempty"`
+	State  WindowState `json:"windowState,omitempty"`
+}
+
+// TargetEventKind says what happened to a DevTools target.
+type TargetEventKind int
+
+const (
+	// TargetCreated: a page, worker, … appeared (also sent once for each
+	// target that already exists when the window is attached).
+	TargetCreated TargetEventKind = iota
+	// TargetNavigated: the target's URL changed.
+	TargetNavigated
+	// TargetDestroyed: the target is gone.
+	TargetDestroyed
+)
+
+func (k TargetEventKind) String() string {
+	switch k {
+	case TargetCreated:
+		return "created"
+	case TargetNavigated:
+		return "navigated"
+	case TargetDestroyed:
+		return "destroyed"
+	}
+	return "unknown"
+}
+
+// TargetEvent reports a change to one of the window's targets (see
+// App.OnTargetEvent). Type is the DevTools target type: "page",
+// "service_worker", "iframe", …
+type TargetEvent struct {
+	Kind     TargetEventKind
+	TargetID string
+	Type     string
+	URL      string
+	Title    string
+}
+
+// DevTools is a Chrome DevTools Protocol client for the app window, over
+// Helium's --remote-debugging-pipe: no port is opened, so no other local
+// process can attach. Get it from App.DevTools; it stops working (with
+// ErrDevToolsClosed) when the window exits, and a relaunched window has a
+// new one.
+type DevTools struct {
+	r io.ReadCloser
+	w io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	nextID    int64
+	pending   map[int64]chan cdpMessage
+	closed    bool
+	done      chan struct{}
+	targets   map[string]cdpTargetInfo
+	page      string // attached page target
+	sessionID string
+	onEvent   func(TargetEvent)
+	events    []TargetEvent
+	wake      chan struct{}
+	closeOnce sync.Once
+}
+
+type cdpRequest struct {
+	ID        int64  `json:"id"`
+	Method    string `json:"method"`
+	Params    any    `json:"params,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// cdpMessage is a reply (ID set) or an event from the browser.
+type cdpMessage struct {
+	ID        int64           `json:"id"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	SessionID string          `json:"sessionId"`
+	Result    json.RawMessage `json:"result"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type cdpTargetInfo struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+}
+
+// newDevTools wraps the two pipe ends (r: browser→us, w: us→browser). It
+// does nothing until start.
+func newDevTools(r io.ReadCloser, w io.WriteCloser) *DevTools {
+	return &DevTools{
+		r:       r,
+		w:       w,
+		pending: make(map[int64]chan cdpMessage),
+		done:    make(chan struct{}),
+		targets: make(map[string]cdpTargetInfo),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// start reads replies and events, and turns on target discovery. onEvent
+// (may be nil) runs on its own goroutine, in order, so it may call back
+// into d.
+func (d *DevTools) start(onEvent func(TargetEvent)) {
+	d.onEvent = onEvent
+	go d.readLoop()
+	go d.dispatchLoop()
+	go func() {
+		// Fails only if the window is already gone.
+		_ = d.call(context.Background(), "", "Target.setDiscoverTargets", map[string]any{"discover": true}, nil)
+	}()
+}
+
+// close drops the connection; pending and later calls get ErrDevToolsClosed.
+func (d *DevTools) close() {
+	d.closeOnce.Do(func() {
+		d.mu.Lock()
+		d.closed = true
+		pending := d.pending
+		d.pending = nil
+		d.mu.Unlock()
+		close(d.done)
+		for _, ch := range pending {
+			close(ch)
+		}
+		_ = d.w.Close()
+		_ = d.r.Close()
+	})
+}
+
+// Messages are JSON, each terminated by a NUL byte.
+func (d *DevTools) readLoop() {
+	defer d.close()
+	br := bufio.NewReader(d.r)
+	for {
+		raw, err := br.ReadBytes(0)
+		if err != nil {
+			return
+		}
+		var msg cdpMessage
+		if err := json.Unmarshal(raw[:len(raw)-1], &msg); err != nil {
+			continue
+		}
+		if msg.ID != 0 {
+			d.mu.Lock()
+			ch := d.pending[msg.ID]
+			delete(d.pending, msg.ID)
+			d.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+			continue
+		}
+		d.handleEvent(msg)
+	}
+}
+
+func (d *DevTools) handleEvent(msg cdpMessage) {
+	if msg.SessionID != "" {
+		// Page-session events; only browser-level target events are used.
+		return
+	}
+	var params struct {
+		TargetInfo cdpTargetInfo `json:"targetInfo"`
+		TargetID   string        `json:"targetId"`
+		SessionID  string        `json:"sessionId"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+	info := params.TargetInfo
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch msg.Method {
+	case "Target.targetCreated":
+		d.targets[info.TargetID] = info
+		d.queue(TargetEvent{Kind: TargetCreated, TargetID: info.TargetID, Type: info.Type, URL: info.URL, Title: info.Title})
+	case "Target.targetInfoChanged":
+		prev, known := d.targets[info.TargetID]
+		d.targets[info.TargetID] = info
+		if known && prev.URL != info.URL {
+			d.queue(TargetEvent{Kind: TargetNavigated, TargetID: info.TargetID, Type: info.Type, URL: info.URL, Title: info.Title})
+		}
+	case "Target.targetDestroyed":
+		prev := d.targets[params.TargetID]
+		delete(d.targets, params.TargetID)
+		if d.page == params.TargetID {
+			d.page, d.sessionID = "", ""
+		}
+		d.queue(TargetEvent{Kind: TargetDestroyed, TargetID: params.TargetID, Type: prev.Type, URL: prev.URL, Title: prev.Title})
+	case "Target.detachedFromTarget":
+		if d.sessionID == params.SessionID {
+			d.page, d.sessionID = "", ""
+		}
+	}
+}
+
+// queue hands ev to dispatchLoop. Caller holds mu.
+func (d *DevTools) queue(ev TargetEvent) {
+	if d.onEvent == nil {
+		return
+	}
+	d.events = append(d.events, ev)
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *DevTools) dispatchLoop() {
+	for {
+		var closed bool
+		select {
+		case <-d.wake:
+		case <-d.done:
+			// Deliver what arrived before the pipe closed, then stop.
+			closed = true
+		}
+		d.mu.Lock()
+		events := d.events
+		d.events = nil
+		d.mu.Unlock()
+		for _, ev := range events {
+			d.onEvent(ev)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// call sends method (to sessionID's page, or the browser when "") and
+// decodes the reply's result into result (when non-nil).
+func (d *DevTools) call(ctx context.Context, sessionID, method string, params, result any) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrDevToolsClosed
+	}
+	d.nextID++
+	id := d.nextID
+	ch := make(chan cdpMessage, 1)
+	d.pending[id] = ch
+	d.mu.Unlock()
+
+	b, err := json.Marshal(cdpRequest{ID: id, Method: method, Params: params, SessionID: sessionID})
+	if err != nil {
+		d.forget(id)
+		return err
+	}
+	d.writeMu.Lock()
+	_, err = d.w.Write(append(b, 0))
+	d.writeMu.Unlock()
+	if err != nil {
+		d.forget(id)
+		return fmt.Errorf("%w: %w", ErrDevToolsClosed, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		d.forget(id)
+		return ctx.Err()
+	case msg, ok := <-ch:
+		if !ok {
+			return ErrDevToolsClosed
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("%w: %s: %s (%d)", ErrDevToolsCall, method, msg.Error.Message, msg.Error.Code)
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	}
+}
+
+func (d *DevTools) forget(id int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, id)
+}
+
+// pageTarget returns the window's page target (the first "page").
+func (d *DevTools) pageTarget(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	page := d.page
+	d.mu.Unlock()
+	if page != "" {
+		return page, nil
+	}
+	var res struct {
+		TargetInfos []cdpTargetInfo `json:"targetInfos"`
+	}
+	if err := d.call(ctx, "", "Target.getTargets", nil, &res); err != nil {
+		return "", err
+	}
+	for _, info := range res.TargetInfos {
+		if info.Type == "page" {
+			return info.TargetID, nil
+		}
+	}
+	return "", ErrNoPageTarget
+}
+
+// session attaches to the page target (once) for page-level commands.
+func (d *DevTools) session(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	sessionID := d.sessionID
+	d.mu.Unlock()
+	if sessionID != "" {
+		return sessionID, nil
+	}
+	page, err := d.pageTarget(ctx)
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := d.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": page, "flatten": true}, &res); err != nil {
+		return "", err
+	}
+	d.mu.Lock()
+	d.page, d.sessionID = page, res.SessionID
+	d.mu.Unlock()
+	return res.SessionID, nil
+}
+
+// Focus brings the window to the front.
+func (d *DevTools) Focus(ctx context.Context) error {
+	page, err := d.pageTarget(ctx)
+	if err != nil {
+		return err
+	}
+	return d.call(ctx, "", "Target.activateTarget", map[string]any{"targetId": page}, nil)
+}
+
+func (d *DevTools) windowID(ctx context.Context) (int, error) {
+	page, err := d.pageTarget(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var res struct {
+		WindowID int `json:"windowId"`
+	}
+	if err := d.call(ctx, "", "Browser.getWindowForTarget", map[string]any{"targetId": page}, &res); err != nil {
+		return 0, err
+	}
+	return res.WindowID, nil
+}
+
+// Bounds returns the window's position, size and state.
+func (d *DevTools) Bounds(ctx context.Context) (WindowBounds, error) {
+	id, err := d.windowID(ctx)
+	if err != nil {
+		return WindowBounds{}, err
+	}
+	var res struct {
+		Bounds WindowBounds `json:"bounds"`
+	}
+	if err := d.call(ctx, "", "Browser.getWindowBounds", map[string]any{"windowId": id}, &res); err != nil {
+		return WindowBounds{}, err
+	}
+	return res.Bounds, nil
+}
+
+// SetBounds moves, resizes or changes the state of the window. A state
+// other than WindowNormal ignores the other fields (the browser refuses the
+// mix); zero fields are left as they are.
+func (d *DevTools) SetBounds(ctx context.Context, b WindowBounds) error {
+	id, err := d.windowID(ctx)
+	if err != nil {
+		return err
+	}
+	if b.State != "" && b.State != WindowNormal {
+		b = WindowBounds{State: b.State}
+	}
+	return d.call(ctx, "", "Browser.setWindowBounds", map[string]any{"windowId": id, "bounds": b}, nil)
+}
+
+// SetTitle sets the window title: an --app window shows the page's
+// document.title, so this sets that. The page may set it again (on
+// navigation, or from its own scripts).
+func (d *DevTools) SetTitle(ctx context.Context, title string) error {
+	quoted, err := json.Marshal(title)
+	if err != nil {
+		return err
+	}
+	return d.Evaluate(ctx, "void (document.title = "+string(quoted)+")", nil)
+}
+
+// Close asks Helium to close gracefully, as if the user closed the window
+// (the profile is flushed; Run then sees the window exit).
+func (d *DevTools) Close(ctx context.Context) error {
+	err := d.call(ctx, "", "Browser.close", nil, nil)
+	if errors.Is(err, ErrDevToolsClosed) {
+		// The browser may exit before replying.
+		return nil
+	}
+	return err
+}
+
+// Reload reloads the page; ignoreCache bypasses the HTTP cache.
+func (d *DevTools) Reload(ctx context.Context, ignoreCache bool) error {
+	sessionID, err := d.session(ctx)
+	if err != nil {
+		return err
+	}
+	return d.call(ctx, sessionID, "Page.reload", map[string]any{"ignoreCache": ignoreCache}, nil)
+}
+
+// Evaluate runs the JavaScript expression in the page, waits for it if it
+// is a promise, and decodes its JSON value into out (when non-nil). A throw
+// or rejection is an ErrScriptException.
+func (d *DevTools) Evaluate(ctx context.Context, expression string, out any) error {
+	sessionID, err := d.session(ctx)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception *struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	params := map[string]any{"expression": expression, "returnByValue": true, "awaitPromise": true}
+	if err := d.call(ctx, sessionID, "Runtime.evaluate", params, &res); err != nil {
+		return err
+	}
+	if ex := res.ExceptionDetails; ex != nil {
+		msg := ex.Text
+		if ex.Exception != nil && ex.Exception.Description != "" {
+			msg = ex.Exception.Description
+		}
+		return fmt.Errorf("%w: %s", ErrScriptException, msg)
+	}
+	if out == nil || len(res.Result.Value) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Result.Value, out)
+}
+
+// DevTools returns the DevTools client of the open app window. Returns
+// ErrNotRunning outside Run, ErrNoWindow in NoUI mode and
+// ErrDevToolsUnavailable while no window is open.
+func (a *App) DevTools() (*DevTools, error) {
+	a.mu.Lock()
+	rs := a.run
+	a.mu.Unlock()
+	if rs == nil {
+		return nil, ErrNotRunning
+	}
+	if rs.bin == "" {
+		return nil, ErrNoWindow
+	}
+	rs.mu.Lock()
+	win := rs.win
+	rs.mu.Unlock()
+	if win == nil || win.devTools() == nil {
+		return nil, ErrDevToolsUnavailable
+	}
+	return win.devTools(), nil
+}
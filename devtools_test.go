@@ -0,0 +1,350 @@
+package eletrocromo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBrowser is the far end of a DevTools pipe: it answers requests with
+// reply (by method) and can push events.
+type fakeBrowser struct {
+	t     *testing.T
+	out   io.WriteCloser
+	reply func(req cdpRequest, params map[string]any) (result any, errMsg string)
+
+	mu   sync.Mutex
+	seen []cdpRequest
+}
+
+// newFakeBrowser returns a started DevTools wired to a fakeBrowser.
+func newFakeBrowser(t *testing.T, onEvent func(TargetEvent), reply func(cdpRequest, map[string]any) (any, string)) (*DevTools, *fakeBrowser) {
+	t.Helper()
+	d, fb := newFakeBrowserPipe(t, reply)
+	d.start(onEvent)
+	return d, fb
+}
+
+// newFakeBrowserPipe is newFakeBrowser without starting the client, as a
+// BrowserWindow hands it to Run.
+func newFakeBrowserPipe(t *testing.T, reply func(cdpRequest, map[string]any) (any, string)) (*DevTools, *fakeBrowser) {
+	toClientR, toClientW := io.Pipe()
+	toBrowserR, toBrowserW := io.Pipe()
+	fb := &fakeBrowser{t: t, out: toClientW, reply: reply}
+	d := newDevTools(toClientR, toBrowserW)
+	go fb.serve(toBrowserR)
+	t.Cleanup(d.close)
+	return d, fb
+}
+
+func (fb *fakeBrowser) serve(in io.Reader) {
+	br := bufio.NewReader(in)
+	for {
+		raw, err := br.ReadBytes(0)
+		if err != nil {
+			return
+		}
+		var req cdpRequest
+		var params struct {
+			Params map[string]any `json:"params"`
+		}
+		if err := json.Unmarshal(raw[:len(raw)-1], &req); err != nil {
+			fb.t.Errorf("bad request %q: %v", raw, err)
+			return
+		}
+		_ = json.Unmarshal(raw[:len(raw)-1], &params)
+		req.Params = params.Params
+		fb.mu.Lock()
+		fb.seen = append(fb.seen, req)
+		fb.mu.Unlock()
+
+		result, errMsg := fb.reply(req, params.Params)
+		msg := map[string]any{"id": req.ID}
+		if errMsg != "" {
+			msg["error"] = map[string]any{"code": -32000, "message": errMsg}
+		} else {
+			if result == nil {
+				result = map[string]any{}
+			}
+			msg["result"] = result
+		}
+		fb.send(msg)
+	}
+}
+
+func (fb *fakeBrowser) send(msg any) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		fb.t.Error(err)
+		return
+	}
+	// Fails once the client or the test closed the pipe; nothing to report.
+	_, _ = fb.out.Write(append(b, 0))
+}
+
+func (fb *fakeBrowser) requests() []cdpRequest {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return slices.Clone(fb.seen)
+}
+
+func (fb *fakeBrowser) find(method string) (cdpRequest, bool) {
+	for _, req := range fb.requests() {
+		if req.Method == method {
+			return req, true
+		}
+	}
+	return cdpRequest{}, false
+}
+
+// appWindowBrowser answers like a Helium --app window with one page.
+func appWindowBrowser(req cdpRequest, params map[string]any) (any, string) {
+	switch req.Method {
+	case "Target.getTargets":
+		return map[string]any{"targetInfos": []any{
+			map[string]any{"targetId": "SW", "type": "service_worker", "url": "http://127.0.0.1:1/sw.js"},
+			map[string]any{"targetId": "PAGE", "type": "page", "url": "http://127.0.0.1:1/"},
+		}}, ""
+	case "Target.attachToTarget":
+		if params["targetId"] != "PAGE" || params["flatten"] != true {
+			return nil, "bad attach"
+		}
+		return map[string]any{"sessionId": "S1"}, ""
+	case "Browser.getWindowForTarget":
+		return map[string]any{"windowId": 7}, ""
+	case "Browser.getWindowBounds":
+		return map[string]any{"bounds": map[string]any{"left": 10, "top": 20, "width": 800, "height": 600, "windowState": "normal"}}, ""
+	case "Runtime.evaluate":
+		if req.SessionID != "S1" {
+			return nil, "no session"
+		}
+		if params["expression"] == "boom()" {
+			return map[string]any{
+				"result":           map[string]any{"type": "object"},
+				"exceptionDetails": map[string]any{"text": "Uncaught", "exception": map[string]any{"description": "ReferenceError: boom is not defined"}},
+			}, ""
+		}
+		return map[string]any{"result": map[string]any{"type": "object", "value": map[string]any{"title": "App"}}}, ""
+	case "Page.reload", "Target.activateTarget", "Browser.setWindowBounds", "Target.setDiscoverTargets":
+		return nil, ""
+	}
+	return nil, "'" + req.Method + "' wasn't found"
+}
+
+func TestDevTools_Commands(t *testing.T) {
+	d, fb := newFakeBrowser(t, nil, appWindowBrowser)
+	ctx := t.Context()
+
+	if err := d.Focus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if req, _ := fb.find("Target.activateTarget"); req.Params.(map[string]any)["targetId"] != "PAGE" {
+		t.Fatalf("activateTarget = %+v", req)
+	}
+
+	b, err := d.Bounds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (WindowBounds{Left: 10, Top: 20, Width: 800, Height: 600, State: WindowNormal}); b != want {
+		t.Fatalf("bounds = %+v, want %+v", b, want)
+	}
+	if err := d.SetBounds(ctx, WindowBounds{Width: 420, Height: 720, State: WindowMaximized}); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := fb.find("Browser.setWindowBounds")
+	set, _ := json.Marshal(req.Params)
+	if string(set) != `{"bounds":{"windowState":"maximized"},"windowId":7}` {
+		t.Fatalf("setWindowBounds params = %s", set)
+	}
+
+	var got struct{ Title string }
+	if err := d.Evaluate(ctx, "({title: document.title})", &got); err != nil || got.Title != "App" {
+		t.Fatalf("Evaluate = %+v, %v", got, err)
+	}
+	if err := d.Evaluate(ctx, "boom()", nil); !errors.Is(err, ErrScriptException) || !strings.Contains(err.Error(), "ReferenceError") {
+		t.Fatalf("throwing Evaluate err = %v", err)
+	}
+	if err := d.SetTitle(ctx, `Notes "draft" </script>`); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(fb.requests(), func(req cdpRequest) bool {
+		return req.Method == "Runtime.evaluate" &&
+			req.Params.(map[string]any)["expression"] == `void (document.title = "Notes \"draft\" \u003c/script\u003e")`
+	}) {
+		t.Fatalf("SetTitle not evaluated in the page: %+v", fb.requests())
+	}
+	if err := d.Reload(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if req, _ := fb.find("Page.reload"); req.SessionID != "S1" {
+		t.Fatalf("Page.reload not sent to the page session: %+v", req)
+	}
+	attaches := 0
+	for _, req := range fb.requests() {
+		if req.Method == "Target.attachToTarget" {
+			attaches++
+		}
+	}
+	if attaches != 1 {
+		t.Fatalf("attached %d times, want once", attaches)
+	}
+}
+
+func TestDevTools_ErrorReply(t *testing.T) {
+	d, _ := newFakeBrowser(t, nil, func(req cdpRequest, _ map[string]any) (any, string) {
+		if req.Method == "Target.getTargets" {
+			return map[string]any{"targetInfos": []any{}}, ""
+		}
+		return nil, "Browser window not found"
+	})
+	if err := d.Close(t.Context()); !errors.Is(err, ErrDevToolsCall) || !strings.Contains(err.Error(), "Browser window not found") {
+		t.Fatalf("Close err = %v", err)
+	}
+	if err := d.Focus(t.Context()); !errors.Is(err, ErrNoPageTarget) {
+		t.Fatalf("Focus err = %v", err)
+	}
+}
+
+func TestDevTools_TargetEvents(t *testing.T) {
+	events := make(chan TargetEvent, 8)
+	_, fb := newFakeBrowser(t, func(ev TargetEvent) { events <- ev }, appWindowBrowser)
+
+	info := func(url string) map[string]any {
+		return map[string]any{"targetId": "PAGE", "type": "page", "url": url, "title": "App"}
+	}
+	fb.send(map[string]any{"method": "Target.targetCreated", "params": map[string]any{"targetInfo": info("http://127.0.0.1:1/")}})
+	fb.send(map[string]any{"method": "Target.targetInfoChanged", "params": map[string]any{"targetInfo": info("http://127.0.0.1:1/")}})
+	fb.send(map[string]any{"method": "Target.targetInfoChanged", "params": map[string]any{"targetInfo": info("https://example.com/")}})
+	fb.send(map[string]any{"method": "Target.targetInfoChanged", "params": map[string]any{"targetInfo": info("https://example.com/")}, "sessionId": "S1"})
+	fb.send(map[string]any{"method": "Target.targetDestroyed", "params": map[string]any{"targetId": "PAGE"}})
+
+	want := []TargetEvent{
+		{Kind: TargetCreated, TargetID: "PAGE", Type: "page", URL: "http://127.0.0.1:1/", Title: "App"},
+		{Kind: TargetNavigated, TargetID: "PAGE", Type: "page", URL: "https://example.com/", Title: "App"},
+		{Kind: TargetDestroyed, TargetID: "PAGE", Type: "page", URL: "https://example.com/", Title: "App"},
+	}
+	for i, w := range want {
+		select {
+		case ev := <-events:
+			if ev != w {
+				t.Fatalf("event %d = %+v, want %+v", i, ev, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d never came", i)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("extra event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, ok := fb.find("Target.setDiscoverTargets"); !ok {
+		// start sends it asynchronously; it has been answered by now.
+		t.Fatal("target discovery not enabled")
+	}
+}
+
+func TestDevTools_ClosedPipe(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	d, fb := newFakeBrowser(t, nil, func(req cdpRequest, params map[string]any) (any, string) {
+		if req.Method == "Runtime.evaluate" {
+			<-hang
+		}
+		return appWindowBrowser(req, params)
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- d.Evaluate(context.Background(), "1", nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_ = fb.out.Close() // the browser exited
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrDevToolsClosed) {
+			t.Fatalf("pending call err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending call not released")
+	}
+	if err := d.Focus(t.Context()); !errors.Is(err, ErrDevToolsClosed) {
+		t.Fatalf("call after close err = %v", err)
+	}
+}
+
+func TestStartAppWindow_DevToolsPipeOnlyWhenAsked(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "5")
+	for _, devTools := range []bool{false, true} {
+		w, err := startAppWindow(script, "http://127.0.0.1:9/", t.TempDir(), devTools)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(3 * time.Second)
+		for countLaunches(t, launches) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Stop()
+		_ = w.Wait()
+		b, err := os.ReadFile(launches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hasPipe := slices.Contains(strings.Fields(string(b)), "--remote-debugging-pipe")
+		if hasPipe != (devTools && devToolsPipeSupported) || (w.DevTools() != nil) != hasPipe {
+			t.Fatalf("devTools=%v: argv = %s, client = %v", devTools, b, w.DevTools() != nil)
+		}
+		if err := os.Remove(launches); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRun_WindowHasDevToolsPipe(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "0.5")
+	host := scriptHost(t, script)
+
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.devtools"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+	)
+	if _, err := app.DevTools(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("DevTools before Run err = %v", err)
+	}
+	callErr := make(chan error, 1)
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if d, err := app.DevTools(); err == nil {
+				// The fake never answers: the call ends when the window exits.
+				callErr <- d.Focus(context.Background())
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		callErr <- errors.New("no DevTools while the window was open")
+	}()
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-callErr; !errors.Is(err, ErrDevToolsClosed) {
+		t.Fatalf("call err = %v", err)
+	}
+	b, err := os.ReadFile(launches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(strings.Fields(string(b)), "--remote-debugging-pipe") {
+		t.Fatalf("argv = %s", b)
+	}
+}
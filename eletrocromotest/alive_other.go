@@ -0,0 +1,7 @@
+//go:build !unix
+
+package eletrocromotest
+
+func processAlive(int) bool {
+	return false
+}
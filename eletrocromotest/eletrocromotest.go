@@ -0,0 +1,208 @@
+// Package eletrocromotest runs an eletrocromo App end to end without Helium.
+//
+// NewHelium builds a fake helium executable (with the go tool) that behaves
+// like the app window as far as Run can tell: it requires --app and
+// --user-data-dir, loads the --app URL with its bootstrap token, follows the
+// redirect and keeps the session cookie, optionally starts helper processes,
+// answers DevTools on the pipe Run opens (targets, window bounds, focus,
+// Browser.close), and stays up until told to exit or crash:
+//
+//	func TestWindowClose(t *testing.T) {
+//		eletrocromotest.Isolate(t)
+//		fake := eletrocromotest.NewHelium(t, eletrocromotest.Options{Helpers: 2})
+//		opened := make(chan int, 1)
+//		app := eletrocromo.New(handler,
+//			eletrocromo.WithID("com.example.app"),
+//			eletrocromo.WithBrowserHost(fake.Host()),
+//			eletrocromo.WithOnWindowOpened(func(pid int) { opened <- pid }),
+//		)
+//		errc := make(chan error, 1)
+//		go func() { errc <- app.Run() }()
+//		l := fake.WaitLaunch(t, 1)
+//		if l.SessionStatus != http.StatusOK { … }
+//		<-opened
+//		fake.Exit() // the user closes the window: Run returns
+//		…
+//	}
+//
+// Run counts a window that exits during its startup grace (2s) as a failed
+// launch (ErrHeliumLaunch), so wait for OnWindowOpened before Exit or Crash
+// to test a close; each such launch costs a test about that long.
+package eletrocromotest
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewtec/eletrocromo"
+	"github.com/lewtec/eletrocromo/eletrocromotest/internal/fakeproto"
+)
+
+// fakePackage is built by NewHelium.
+const fakePackage = "github.com/lewtec/eletrocromo/eletrocromotest/fakehelium"
+
+// WaitTimeout bounds WaitLaunch.
+var WaitTimeout = 15 * time.Second
+
+// Options shapes every launch of a fake Helium.
+type Options struct {
+	// CrashOnStart makes each launch exit with an error at once, so Run
+	// fails with eletrocromo.ErrHeliumLaunch.
+	CrashOnStart bool
+	// Helpers is how many helper processes each launch starts (like
+	// Chromium's renderer and GPU processes). They only go away with the
+	// process tree, so Alive tells whether Run killed the tree.
+	Helpers int
+}
+
+// Launch is one fake Helium process, reported once it loaded the app.
+type Launch struct {
+	PID        int
+	HelperPIDs []int
+	// Args is the full command line after the binary.
+	Args []string
+	// AppURL is the --app value, bootstrap token included.
+	AppURL      string
+	UserDataDir string
+	// Status is the final HTTP status of loading AppURL (after the
+	// bootstrap redirect).
+	Status int
+	// SessionStatus is the status of a second request to the app's origin
+	// that carries only the session cookie.
+	SessionStatus int
+	// FetchError is why loading the app failed, if it did.
+	FetchError string
+	Started    time.Time
+}
+
+// Helium is a fake helium executable in its own directory.
+type Helium struct {
+	// Path is the executable, for eletrocromo.HeliumHost.Path.
+	Path string
+	dir  string
+}
+
+// NewHelium builds the fake into a fresh t.TempDir. It needs the go tool
+// (go test puts it on PATH) and fails t when the build fails.
+func NewHelium(t testing.TB, opts Options) *Helium {
+	t.Helper()
+	dir := t.TempDir()
+	name := "helium"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	h := &Helium{Path: filepath.Join(dir, name), dir: dir}
+	cmd := exec.Command("go", "build", "-o", h.Path, fakePackage)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("eletrocromotest: build fake helium: %v\n%s", err, out)
+	}
+	b, err := json.Marshal(fakeproto.Config{CrashOnStart: opts.CrashOnStart, Helpers: opts.Helpers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fakeproto.WriteFile(filepath.Join(dir, fakeproto.ConfigFile), b); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// Host is a HeliumHost pinned to the fake, for eletrocromo.WithBrowserHost.
+func (h *Helium) Host() *eletrocromo.HeliumHost {
+	return &eletrocromo.HeliumHost{Path: h.Path}
+}
+
+// Launches returns the launches that have loaded the app so far, oldest
+// first.
+func (h *Helium) Launches() []Launch {
+	paths, _ := filepath.Glob(filepath.Join(h.dir, "launch-*.json"))
+	var launches []Launch
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var l fakeproto.Launch
+		if err := json.Unmarshal(b, &l); err != nil {
+			continue
+		}
+		launches = append(launches, Launch(l))
+	}
+	slices.SortFunc(launches, func(a, b Launch) int {
+		return a.Started.Compare(b.Started)
+	})
+	return launches
+}
+
+// WaitLaunch waits until n launches have loaded the app and returns the
+// nth. It fails t after WaitTimeout.
+func (h *Helium) WaitLaunch(t testing.TB, n int) Launch {
+	t.Helper()
+	deadline := time.Now().Add(WaitTimeout)
+	for {
+		if launches := h.Launches(); len(launches) >= n {
+			return launches[n-1]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("eletrocromotest: fake helium launch %d did not load the app within %s", n, WaitTimeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Exit makes the latest launch exit cleanly, as when the user closes the
+// window.
+func (h *Helium) Exit() error {
+	return h.command(fakeproto.CommandExit)
+}
+
+// Crash makes the latest launch exit with an error.
+func (h *Helium) Crash() error {
+	return h.command(fakeproto.CommandCrash)
+}
+
+func (h *Helium) command(cmd string) error {
+	launches := h.Launches()
+	if len(launches) == 0 {
+		return os.ErrNotExist
+	}
+	return fakeproto.WriteFile(fakeproto.CommandFile(h.dir, launches[len(launches)-1].PID), []byte(cmd))
+}
+
+// Alive reports whether pid is still a live process (not a zombie). It
+// always reports false on Windows.
+func Alive(pid int) bool {
+	return processAlive(pid)
+}
+
+// Isolate points eletrocromo's per-user state (profiles, session keys,
+// instance locks) at fresh temp dirs for t and turns off Helium ensure, so
+// tests neither touch the real profile nor download anything. Like
+// t.Setenv, it cannot be used in parallel tests.
+func Isolate(t testing.TB) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	// Instance sockets live here; t.TempDir paths can exceed the unix
+	// socket path limit.
+	dir, err := os.MkdirTemp("", "ecrt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv("ELETROCROMO_NO_ENSURE", "1")
+}
+
+// HasArg reports whether l was launched with flag (with or without a
+// value).
+func (l Launch) HasArg(flag string) bool {
+	return slices.ContainsFunc(l.Args, func(arg string) bool {
+		return arg == flag || strings.HasPrefix(arg, flag+"=")
+	})
+}
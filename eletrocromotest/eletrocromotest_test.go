@@ -0,0 +1,211 @@
+package eletrocromotest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/lewtec/eletrocromo"
+	"github.com/lewtec/eletrocromo/eletrocromotest"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	if _, err := io.WriteString(w, "ok"); err != nil {
+		return
+	}
+})
+
+func startRun(t *testing.T, app *eletrocromo.App) <-chan error {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- app.Run() }()
+	return errc
+}
+
+func waitRun(t *testing.T, errc <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(15 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+// openedHook reports App.OnWindowOpened: Run counts a window that exits
+// during its startup grace as a failed launch, so close or crash it only
+// after this fired.
+func openedHook() (eletrocromo.Option, <-chan int) {
+	opened := make(chan int, 1)
+	return eletrocromo.WithOnWindowOpened(func(pid int) { opened <- pid }), opened
+}
+
+func waitOpened(t *testing.T, opened <-chan int) int {
+	t.Helper()
+	select {
+	case pid := <-opened:
+		return pid
+	case <-time.After(15 * time.Second):
+		t.Fatal("window never opened")
+		return 0
+	}
+}
+
+func requireUnix(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups and helper liveness are Unix-only")
+	}
+}
+
+func TestRun_ReturnsWhenWindowCloses(t *testing.T) {
+	eletrocromotest.Isolate(t)
+	fake := eletrocromotest.NewHelium(t, eletrocromotest.Options{Helpers: 1})
+	onOpened, opened := openedHook()
+	app := eletrocromo.New(okHandler,
+		eletrocromo.WithID("br.tec.lew.test.harness_close"),
+		eletrocromo.WithContext(t.Context()),
+		eletrocromo.WithBrowserHost(fake.Host()),
+		onOpened,
+	)
+	errc := startRun(t, app)
+
+	l := fake.WaitLaunch(t, 1)
+	if l.FetchError != "" || l.Status != http.StatusOK || l.SessionStatus != http.StatusOK {
+		t.Fatalf("app load: status %d, session %d, err %q", l.Status, l.SessionStatus, l.FetchError)
+	}
+	profile, err := eletrocromo.ProfileDir("br.tec.lew.test.harness_close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.UserDataDir != profile || !l.HasArg("--no-first-run") {
+		t.Fatalf("launch = %+v", l)
+	}
+	if pid := waitOpened(t, opened); pid != l.PID {
+		t.Fatalf("opened pid %d, launched %d", pid, l.PID)
+	}
+
+	if err := fake.Exit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitRun(t, errc); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+}
+
+func TestRun_CrashOnStartIsLaunchError(t *testing.T) {
+	eletrocromotest.Isolate(t)
+	fake := eletrocromotest.NewHelium(t, eletrocromotest.Options{CrashOnStart: true})
+	app := eletrocromo.New(okHandler,
+		eletrocromo.WithID("br.tec.lew.test.harness_crash_start"),
+		eletrocromo.WithContext(t.Context()),
+		eletrocromo.WithBrowserHost(fake.Host()),
+	)
+	if err := app.Run(); !errors.Is(err, eletrocromo.ErrHeliumLaunch) {
+		t.Fatalf("Run = %v, want ErrHeliumLaunch", err)
+	}
+}
+
+func TestRun_CrashReachesOnWindowClosed(t *testing.T) {
+	eletrocromotest.Isolate(t)
+	fake := eletrocromotest.NewHelium(t, eletrocromotest.Options{})
+	onOpened, opened := openedHook()
+	closed := make(chan error, 1)
+	app := eletrocromo.New(okHandler,
+		eletrocromo.WithID("br.tec.lew.test.harness_crash"),
+		eletrocromo.WithContext(t.Context()),
+		eletrocromo.WithBrowserHost(fake.Host()),
+		eletrocromo.WithOnWindowClosed(func(err error) { closed <- err }),
+		onOpened,
+	)
+	errc := startRun(t, app)
+	fake.WaitLaunch(t, 1)
+	waitOpened(t, opened)
+	if err := fake.Crash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitRun(t, errc); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if err := <-closed; err == nil {
+		t.Fatal("OnWindowClosed got nil for a crash")
+	}
+}
+
+func TestRun_ShutdownKillsProcessTree(t *testing.T) {
+	requireUnix(t)
+	eletrocromotest.Isolate(t)
+	fake := eletrocromotest.NewHelium(t, eletrocromotest.Options{Helpers: 2})
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := eletrocromo.New(okHandler,
+		eletrocromo.WithID("br.tec.lew.test.harness_tree"),
+		eletrocromo.WithContext(ctx),
+		eletrocromo.WithBrowserHost(fake.Host()),
+	)
+	errc := startRun(t, app)
+	l := fake.WaitLaunch(t, 1)
+	for _, pid := range append([]int{l.PID}, l.HelperPIDs...) {
+		if !eletrocromotest.Alive(pid) {
+			t.Fatalf("pid %d not running before shutdown", pid)
+		}
+	}
+
+	cancel()
+	if err := waitRun(t, errc); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, pid := range append([]int{l.PID}, l.HelperPIDs...) {
+		for eletrocromotest.Alive(pid) {
+			if time.Now().After(deadline) {
+				t.Fatalf("pid %d survived shutdown", pid)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func TestRun_DevToolsReachesFake(t *testing.T) {
+	eletrocromotest.Isolate(t)
+	fake := eletrocromotest.NewHelium(t, eletrocromotest.Options{})
+	onOpened, opened := openedHook()
+	app := eletrocromo.New(okHandler,
+		eletrocromo.WithID("br.tec.lew.test.harness_devtools"),
+		eletrocromo.WithContext(t.Context()),
+		eletrocromo.WithBrowserHost(fake.Host()),
+		onOpened,
+	)
+	errc := startRun(t, app)
+	fake.WaitLaunch(t, 1)
+	waitOpened(t, opened)
+
+	d, err := app.DevTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	if err := d.SetBounds(ctx, eletrocromo.WindowBounds{Left: 10, Top: 20, Width: 800, Height: 600}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.Bounds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Left != 10 || b.Top != 20 || b.Width != 800 || b.Height != 600 || b.State != eletrocromo.WindowNormal {
+		t.Fatalf("Bounds = %+v", b)
+	}
+	// Browser.close ends the window, and with it Run.
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitRun(t, errc); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+}
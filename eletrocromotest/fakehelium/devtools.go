@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// devToolsPipe is the flag Run launches Helium with to drive the window
+// over fds 3 (commands in) and 4 (replies out).
+const devToolsPipe = "--remote-debugging-pipe"
+
+// window is the state the fake's DevTools reports and changes.
+type window struct {
+	mu     sync.Mutex
+	bounds map[string]any
+}
+
+// serveDevTools answers the DevTools commands Run sends (targets, window
+// bounds, focus, close) like Helium's pipe does, so Run's shutdown reads the
+// bounds at once instead of timing out. Commands it does not know get
+// Chromium's "not found" error. Browser.close is acknowledged, then closed
+// is signalled.
+func serveDevTools(appURL string, closed chan<- struct{}) {
+	in := os.NewFile(3, "devtools-in")
+	out := os.NewFile(4, "devtools-out")
+	w := &window{bounds: map[string]any{
+		"left": 100, "top": 100, "width": 1024, "height": 768, "windowState": "normal",
+	}}
+	br := bufio.NewReader(in)
+	for {
+		raw, err := br.ReadBytes(0)
+		if err != nil {
+			return
+		}
+		var req struct {
+			ID     int64          `json:"id"`
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		if err := json.Unmarshal(raw[:len(raw)-1], &req); err != nil {
+			continue
+		}
+		msg := map[string]any{"id": req.ID}
+		if result, ok := w.answer(req.Method, req.Params, appURL); ok {
+			msg["result"] = result
+		} else {
+			msg["error"] = map[string]any{"code": -32601, "message": "'" + req.Method + "' wasn't found"}
+		}
+		b, err := json.Marshal(msg)
+		if err != nil {
+			continue
+		}
+		if _, err := out.Write(append(b, 0)); err != nil {
+			return
+		}
+		if req.Method == "Browser.close" {
+			close(closed)
+			return
+		}
+	}
+}
+
+// answer is the result for method, or false when the fake does not know it.
+func (w *window) answer(method string, params map[string]any, appURL string) (any, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch method {
+	case "Target.getTargets":
+		return map[string]any{"targetInfos": []map[string]any{
+			{"targetId": "page-1", "type": "page", "title": "", "url": appURL},
+		}}, true
+	case "Target.attachToTarget":
+		return map[string]any{"sessionId": "session-1"}, true
+	case "Browser.getWindowForTarget":
+		return map[string]any{"windowId": 1, "bounds": w.bounds}, true
+	case "Browser.getWindowBounds":
+		return map[string]any{"bounds": w.bounds}, true
+	case "Browser.setWindowBounds":
+		if b, ok := params["bounds"].(map[string]any); ok {
+			for k, v := range b {
+				w.bounds[k] = v
+			}
+		}
+		return map[string]any{}, true
+	case "Target.setDiscoverTargets", "Target.activateTarget", "Browser.close":
+		return map[string]any{}, true
+	}
+	return nil, false
+}
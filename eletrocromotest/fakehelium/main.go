@@ -0,0 +1,150 @@
+// Command fakehelium stands in for Helium in end-to-end tests. It is built
+// and driven by eletrocromotest.NewHelium; it reads its config from the
+// directory it lives in.
+//
+// Each launch requires --app and --user-data-dir, starts the configured
+// helper processes, loads the --app URL (bootstrap token, redirect, session
+// cookie), checks that the cookie alone is accepted, reports the results and
+// then waits for an exit or crash command. With --remote-debugging-pipe it
+// also answers DevTools on fds 3 and 4 (see serveDevTools).
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lewtec/eletrocromo/eletrocromotest/internal/fakeproto"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == fakeproto.HelperFlag {
+		// Helpers live until killed, so only a process-tree kill ends them
+		// when the browser itself dies without cleaning up.
+		for {
+			time.Sleep(time.Hour)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fail("%v", err)
+	}
+	dir := filepath.Dir(exe)
+	var cfg fakeproto.Config
+	if b, err := os.ReadFile(filepath.Join(dir, fakeproto.ConfigFile)); err == nil {
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			fail("config: %v", err)
+		}
+	}
+
+	l := fakeproto.Launch{PID: os.Getpid(), Args: os.Args[1:], Started: time.Now()}
+	for _, arg := range l.Args {
+		if v, ok := strings.CutPrefix(arg, "--app="); ok {
+			l.AppURL = v
+		}
+		if v, ok := strings.CutPrefix(arg, "--user-data-dir="); ok {
+			l.UserDataDir = v
+		}
+	}
+	if l.AppURL == "" || l.UserDataDir == "" {
+		fail("--app and --user-data-dir are required")
+	}
+	if cfg.CrashOnStart {
+		fail("crash on start")
+	}
+
+	closed := make(chan struct{})
+	if slices.Contains(l.Args, devToolsPipe) {
+		go serveDevTools(l.AppURL, closed)
+	}
+
+	var helpers []*exec.Cmd
+	for range cfg.Helpers {
+		cmd := exec.Command(exe, fakeproto.HelperFlag)
+		if err := cmd.Start(); err != nil {
+			fail("helper: %v", err)
+		}
+		helpers = append(helpers, cmd)
+		l.HelperPIDs = append(l.HelperPIDs, cmd.Process.Pid)
+	}
+
+	load(&l)
+	b, err := json.Marshal(l)
+	if err != nil {
+		fail("%v", err)
+	}
+	if err := fakeproto.WriteFile(fakeproto.LaunchFile(dir, l.PID), b); err != nil {
+		fail("%v", err)
+	}
+
+	command := fakeproto.CommandFile(dir, l.PID)
+	for {
+		var cmd string
+		select {
+		case <-closed:
+			cmd = fakeproto.CommandExit
+		case <-time.After(20 * time.Millisecond):
+			b, err := os.ReadFile(command)
+			if err != nil {
+				continue
+			}
+			cmd = strings.TrimSpace(string(b))
+		}
+		// Like Chromium, take the helpers down on the way out.
+		for _, h := range helpers {
+			_ = h.Process.Kill()
+			_ = h.Wait()
+		}
+		switch cmd {
+		case fakeproto.CommandExit:
+			os.Exit(0)
+		default:
+			fail("crash on command")
+		}
+	}
+}
+
+// load opens the app URL like the window would, then asks for the origin
+// again with only the cookie jar.
+func load(l *fakeproto.Launch) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		l.FetchError = err.Error()
+		return
+	}
+	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+	resp, err := client.Get(l.AppURL)
+	if err != nil {
+		l.FetchError = err.Error()
+		return
+	}
+	_ = resp.Body.Close()
+	l.Status = resp.StatusCode
+
+	u, err := url.Parse(l.AppURL)
+	if err != nil {
+		l.FetchError = err.Error()
+		return
+	}
+	resp, err = client.Get(u.Scheme + "://" + u.Host + "/")
+	if err != nil {
+		l.FetchError = err.Error()
+		return
+	}
+	_ = resp.Body.Close()
+	l.SessionStatus = resp.StatusCode
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "fakehelium: "+format+"\n", args...)
+	os.Exit(1)
+}
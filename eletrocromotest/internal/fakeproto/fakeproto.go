@@ -0,0 +1,62 @@
+// Package fakeproto is the file protocol between eletrocromotest and the
+// fakehelium binary. Both sides live next to the binary: the harness writes
+// ConfigFile and command files, the fake writes one launch file per process.
+package fakeproto
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ConfigFile holds Config, next to the fake binary.
+const ConfigFile = "fakehelium.json"
+
+// HelperFlag marks a re-exec of the fake as one of its helper processes
+// (Chromium passes --type=renderer, --type=gpu-process, …).
+const HelperFlag = "--type=fakehelium-helper"
+
+// Commands for a running fake.
+const (
+	CommandExit  = "exit"
+	CommandCrash = "crash"
+)
+
+// Config tunes every launch of one fake binary.
+type Config struct {
+	CrashOnStart bool `json:"crash_on_start"`
+	Helpers      int  `json:"helpers"`
+}
+
+// Launch is what one fake process reports once its page has loaded.
+type Launch struct {
+	PID           int       `json:"pid"`
+	HelperPIDs    []int     `json:"helper_pids"`
+	Args          []string  `json:"args"`
+	AppURL        string    `json:"app_url"`
+	UserDataDir   string    `json:"user_data_dir"`
+	Status        int       `json:"status"`
+	SessionStatus int       `json:"session_status"`
+	FetchError    string    `json:"fetch_error,omitempty"`
+	Started       time.Time `json:"started"`
+}
+
+// LaunchFile is where the fake with pid reports its Launch.
+func LaunchFile(dir string, pid int) string {
+	return filepath.Join(dir, "launch-"+strconv.Itoa(pid)+".json")
+}
+
+// CommandFile is where the harness leaves a command for pid.
+func CommandFile(dir string, pid int) string {
+	return filepath.Join(dir, "command-"+strconv.Itoa(pid))
+}
+
+// WriteFile writes path through a rename so readers never see half a file.
+func WriteFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -25,14 +25,14 @@ func workspacedPathOverride() string {
 	return strings.TrimSpace(os.Getenv("ELETROCROMO_WORKSPACED"))
 }
 
-// commandOutput runs name with args and returns stdout. Tests may override.
-// On failure the returned error wraps the underlying Run error (via %w) so
-// callers can use errors.As for *exec.ExitError. When the context is already
+// runCommand runs name with args and returns stdout (HeliumHost runs
+// workspaced through it). On failure the returned error wraps the underlying
+// Run error (via %w) so callers can use errors.As for *exec.ExitError. When the context is already
 // done (cancel/deadline), that error is joined in as well: CommandContext often
 // surfaces a killed child as "signal: killed" rather than ctx.Err(), which used
 // to make errors.Is(err, context.Canceled) fail for ResolveBrowserHost/App.Run.
 // Stderr text is included when present.
-var commandOutput = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, name, args...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -51,14 +51,30 @@ var commandOutput = func(ctx context.Context, name string, args ...string) ([]by
 	return stdout.Bytes(), nil
 }
 
-// ensureHeliumBrowser returns the path to a helium binary, installing via
+// look is exec.LookPath unless a test set h.lookPath.
+func (h *HeliumHost) look(file string) (string, error) {
+	if h.lookPath != nil {
+		return h.lookPath(file)
+	}
+	return exec.LookPath(file)
+}
+
+// output is runCommand unless a test set h.commandOutput.
+func (h *HeliumHost) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if h.commandOutput != nil {
+		return h.commandOutput(ctx, name, args...)
+	}
+	return runCommand(ctx, name, args...)
+}
+
+// ensureHelium returns the path to a helium binary, installing via
 // workspaced tool which helium-browser helium when needed.
-func ensureHeliumBrowser(ctx context.Context) (string, error) {
-	ws, err := resolveWorkspaced(ctx)
+func (h *HeliumHost) ensureHelium(ctx context.Context) (string, error) {
+	ws, err := h.resolveWorkspaced(ctx)
 	if err != nil {
 		return "", err
 	}
-	out, err := commandOutput(ctx, ws, "tool", "which", heliumBrowserTool, heliumBrowserBin)
+	out, err := h.output(ctx, ws, "tool", "which", heliumBrowserTool, heliumBrowserBin)
 	if err != nil {
 		return "", fmt.Errorf("workspaced ensure %s: %w", heliumBrowserTool, err)
 	}
@@ -75,19 +91,21 @@ func ensureHeliumBrowser(ctx context.Context) (string, error) {
 	if path == "" {
 		return "", fmt.Errorf("%w: %s %s", ErrEnsureHeliumEmptyPath, heliumBrowserTool, heliumBrowserBin)
 	}
+	h.logger().Info("Helium ensured", "bin", path)
 	return path, nil
 }
 
-// resolveWorkspaced returns a workspaced binary path: env override, PATH, or bootstrap.
-func resolveWorkspaced(ctx context.Context) (string, error) {
-	if p := workspacedPathOverride(); p != "" {
+// resolveWorkspaced returns a workspaced binary path: explicit override
+// (WorkspacedPath / ELETROCROMO_WORKSPACED), PATH, or bootstrap.
+func (h *HeliumHost) resolveWorkspaced(ctx context.Context) (string, error) {
+	if p := h.workspacedPath(); p != "" {
 		if _, err := os.Stat(p); err != nil {
-			return "", fmt.Errorf("ELETROCROMO_WORKSPACED=%q: %w", p, err)
+			return "", fmt.Errorf("workspaced path %q: %w", p, err)
 		}
 		return p, nil
 	}
-	if p, err := lookPath("workspaced"); err == nil {
+	if p, err := h.look("workspaced"); err == nil {
 		return p, nil
 	}
-	return bootstrapWorkspaced(ctx)
+	return h.bootstrapWorkspaced(ctx)
 }
@@ -19,7 +19,7 @@ func TestCommandOutput_PreservesContextCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(t.Context())
 	done := make(chan error, 1)
 	go func() {
-		_, err := commandOutput(ctx, "sleep", "30")
+		_, err := runCommand(ctx, "sleep", "30")
 		done <- err
 	}()
 	time.Sleep(50 * time.Millisecond)
@@ -34,14 +34,14 @@ func TestCommandOutput_PreservesContextCanceled(t *testing.T) {
 			t.Fatalf("want errors.Is(..., context.Canceled), got %v", err)
 		}
 	case <-time.After(5 * time.Second):
-		t.Fatal("commandOutput did not return after cancel")
+		t.Fatal("runCommand did not return after cancel")
 	}
 }
 
 func TestCommandOutput_AlreadyCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(t.Context())
 	cancel()
-	_, err := commandOutput(ctx, "true")
+	_, err := runCommand(ctx, "true")
 	if err == nil {
 		t.Fatal("expected error when context already canceled")
 	}
@@ -52,7 +52,7 @@ func TestCommandOutput_AlreadyCanceled(t *testing.T) {
 
 func TestCommandOutput_PreservesExitError(t *testing.T) {
 	// false exits 1 with empty stderr on most systems.
-	_, err := commandOutput(t.Context(), "false")
+	_, err := runCommand(t.Context(), "false")
 	if err == nil {
 		t.Fatal("expected non-zero exit")
 	}
@@ -72,7 +72,7 @@ func TestCommandOutput_IncludesStderrAndWraps(t *testing.T) {
 	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
 		t.Fatal(err)
 	}
-	_, err := commandOutput(t.Context(), script)
+	_, err := runCommand(t.Context(), script)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -61,6 +61,13 @@ func main() {
 			}
 		}),
 		Context: ctx,
+		// Astro inlines page <style> without a nonce; relax style-src only,
+		// keeping the rest of the default policy.
+		SecurityHeaders: map[string]string{
+			"Content-Security-Policy": "default-src 'self'; style-src 'self' 'unsafe-inline'; " +
+				"img-src 'self' data:; object-src 'none'; base-uri 'none'; " +
+				"form-action 'self'; frame-ancestors 'none'",
+		},
 	}
 	log.Printf("astro example: launching Helium window (//go:embed guest + assets)")
 	if err := app.Run(); err != nil {
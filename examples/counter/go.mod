@@ -4,6 +4,10 @@ go 1.25.6
 
 require github.com/lewtec/eletrocromo v0.0.0
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/godbus/dbus/v5 v5.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/sys v0.46.0 // indirect
+)
 
 replace github.com/lewtec/eletrocromo => ../..
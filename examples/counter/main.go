@@ -2,12 +2,15 @@
 //
 //	mise run example:counter
 //	# or: go -C examples/counter run .
+//	# keep running after the window closes:
+//	go -C examples/counter run . -background
 //
-// Ctrl+C shuts down the process (window-owned lifetime is not wired yet).
+// By default closing the window exits the process; Ctrl+C always does.
 package main
 
 import (
 	"context"
+	"flag"
 	"html/template"
 	"log"
 	"net/http"
@@ -24,7 +27,7 @@ var page = template.Must(template.New("counter").Parse(`<!DOCTYPE html>
   <meta charset="utf-8">
   <meta name="viewport" content="width=device-width, initial-scale=1">
   <title>eletrocromo counter</title>
-  <style>
+  <style nonce="{{.Nonce}}">
     :root { color-scheme: light dark; font-family: system-ui, sans-serif; }
     body { max-width: 28rem; margin: 3rem auto; padding: 0 1rem; text-align: center; }
     h1 { font-size: 1.25rem; font-weight: 600; }
@@ -36,6 +39,7 @@ var page = template.Must(template.New("counter").Parse(`<!DOCTYPE html>
       background: color-mix(in srgb, CanvasText 8%, Canvas); cursor: pointer;
     }
     button:hover { background: color-mix(in srgb, CanvasText 14%, Canvas); }
+    form.reset { display: block; margin-top: 0.75rem; }
     p.hint { margin-top: 2rem; font-size: 0.85rem; opacity: 0.7; }
   </style>
 </head>
@@ -46,10 +50,10 @@ var page = template.Must(template.New("counter").Parse(`<!DOCTYPE html>
     <button type="submit" name="op" value="dec">−</button>
     <button type="submit" name="op" value="inc">+</button>
   </form>
-  <form method="POST" action="/" style="display:block;margin-top:0.75rem">
+  <form class="reset" method="POST" action="/">
     <button type="submit" name="op" value="reset">reset</button>
   </form>
-  <p class="hint">Server-rendered with Go html/template. Close with Ctrl+C in the terminal.</p>
+  <p class="hint">Server-rendered with Go html/template. Closing the window exits the app.</p>
 </body>
 </html>
 `))
@@ -90,7 +94,9 @@ func main() {
 			return
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := page.Execute(w, map[string]int64{"Count": count.Load()}); err != nil {
+		// The default CSP only allows inline <style> carrying this nonce.
+		data := map[string]any{"Count": count.Load(), "Nonce": eletrocromo.CSPNonce(r.Context())}
+		if err := page.Execute(w, data); err != nil {
 			log.Printf("template: %v", err)
 		}
 	})
@@ -100,6 +106,8 @@ func main() {
 		Handler: mux,
 		Context: ctx,
 	}
+	app.BindFlags(nil)
+	flag.Parse()
 	log.Printf("counter example: launching app window (Helium-first host resolve)")
 	if err := app.Run(); err != nil {
 		log.Fatal(err)
@@ -1,5 +1,5 @@
-// Ticker is a dogfood app: a background goroutine increments a counter every
-// second; the UI is a read-only html/template at GET /.
+// Ticker is a dogfood app: a periodic background task increments a counter
+// every second; the UI is a read-only html/template at GET /.
 //
 //	mise run example:ticker
 //	# or: go -C examples/ticker run .
@@ -27,7 +27,7 @@ var page = template.Must(template.New("ticker").Parse(`<!DOCTYPE html>
   <meta name="viewport" content="width=device-width, initial-scale=1">
   <meta http-equiv="refresh" content="1">
   <title>eletrocromo ticker</title>
-  <style>
+  <style nonce="{{.Nonce}}">
     :root { color-scheme: light dark; font-family: system-ui, sans-serif; }
     body { max-width: 28rem; margin: 3rem auto; padding: 0 1rem; text-align: center; }
     h1 { font-size: 1.25rem; font-weight: 600; }
@@ -41,7 +41,7 @@ var page = template.Must(template.New("ticker").Parse(`<!DOCTYPE html>
   <p class="count">{{.Count}}</p>
   <p class="meta">seconds since start (server clock)</p>
   <p class="hint">
-    A Go goroutine adds 1 every second. This page only reads the value
+    A periodic eletrocromo task adds 1 every second. This page only reads the value
     (server-rendered template; auto-refresh each second).
   </p>
 </body>
@@ -54,20 +54,6 @@ func main() {
 
 	var count atomic.Int64
 
-	// Background producer: only the server mutates count.
-	go func() {
-		t := time.NewTicker(time.Second)
-		defer t.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-t.C:
-				count.Add(1)
-			}
-		}
-	}()
-
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -80,7 +66,9 @@ func main() {
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Header().Set("Cache-Control", "no-store")
-		if err := page.Execute(w, map[string]int64{"Count": count.Load()}); err != nil {
+		// The default CSP only allows inline <style> carrying this nonce.
+		data := map[string]any{"Count": count.Load(), "Nonce": eletrocromo.CSPNonce(r.Context())}
+		if err := page.Execute(w, data); err != nil {
 			log.Printf("template: %v", err)
 		}
 	})
@@ -90,6 +78,18 @@ func main() {
 		Handler: mux,
 		Context: ctx,
 	}
+	// Background producer: only the server mutates count. Queued until the
+	// server is up, then runs on (and stops with) Run's context.
+	tick, err := eletrocromo.NewPeriodicTask(time.Second, func(context.Context) error {
+		count.Add(1)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := app.BackgroundRun(eletrocromo.NamedTask("ticker", tick)); err != nil {
+		log.Fatal(err)
+	}
 	log.Printf("ticker example: background +1/s; UI is read-only template")
 	if err := app.Run(); err != nil {
 		log.Fatal(err)
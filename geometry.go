@@ -0,0 +1,321 @@
+package eletrocromo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// windowStateFile holds the last window bounds in the per-ID state dir.
+const windowStateFile = "window.json"
+
+// Limits for restored bounds, in DIPs.
+const (
+	// minRestoredSize: smaller saved sizes are treated as garbage.
+	minRestoredSize = 200
+	// minVisible is how much of the window must stay on the work area for
+	// its saved position to be kept.
+	minVisible = 100
+)
+
+// windowBoundsTimeout bounds the DevTools calls that check and save bounds.
+const windowBoundsTimeout = 2 * time.Second
+
+// workAreaScript reads the work area of the screen the page is on.
+const workAreaScript = `({left: screen.availLeft, top: screen.availTop,
+	right: screen.availLeft + screen.availWidth, bottom: screen.availTop + screen.availHeight})`
+
+// windowBounds are the window's restored (non-maximized) bounds and the
+// display work area they were recorded on.
+type windowBounds struct {
+	X         int        `json:"x"`
+	Y         int        `json:"y"`
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	Maximized bool       `json:"maximized,omitempty"`
+	WorkArea  screenRect `json:"work_area,omitzero"`
+}
+
+type screenRect struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
+}
+
+func (r screenRect) valid() bool {
+	return r.Right > r.Left && r.Bottom > r.Top
+}
+
+// chromePlacement is one entry of the profile's browser.app_window_placement.
+type chromePlacement struct {
+	screenRect
+	Maximized      bool `json:"maximized"`
+	WorkAreaLeft   int  `json:"work_area_left"`
+	WorkAreaTop    int  `json:"work_area_top"`
+	WorkAreaRight  int  `json:"work_area_right"`
+	WorkAreaBottom int  `json:"work_area_bottom"`
+}
+
+// windowBoundsFile is where Run keeps the bounds for a.ID, or "" when they
+// are not remembered (NoRestoreBounds, Kiosk, or no state dir).
+func (a *App) windowBoundsFile() string {
+	if a.Window.NoRestoreBounds || a.Window.Kiosk {
+		return ""
+	}
+	dir, err := stateDir(a.ID)
+	if err != nil {
+		a.logger(phaseWindow).Warn("window bounds will not be remembered", "err", err)
+		return ""
+	}
+	return filepath.Join(dir, windowStateFile)
+}
+
+// readProfileBounds reads the bounds Helium saved for the app window in the
+// profile's Preferences. --app=<url> windows are keyed "<host>_<path>", so
+// the loopback entry is ours; a lone entry of any name is taken too.
+func readProfileBounds(profileDir string) (windowBounds, bool) {
+	b, err := os.ReadFile(filepath.Join(profileDir, "Default", "Preferences"))
+	if err != nil {
+		return windowBounds{}, false
+	}
+	var prefs struct {
+		Browser struct {
+			AppWindowPlacement map[string]chromePlacement `json:"app_window_placement"`
+		} `json:"browser"`
+	}
+	if err := json.Unmarshal(b, &prefs); err != nil {
+		return windowBounds{}, false
+	}
+	placements := prefs.Browser.AppWindowPlacement
+	var p chromePlacement
+	found := false
+	for _, key := range slices.Sorted(maps.Keys(placements)) {
+		host, _, _ := strings.Cut(key, "_")
+		ip := net.ParseIP(strings.Trim(host, "[]"))
+		if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			p, found = placements[key], true
+			break
+		}
+	}
+	if !found && len(placements) == 1 {
+		for _, only := range placements {
+			p, found = only, true
+		}
+	}
+	if !found || !p.valid() {
+		return windowBounds{}, false
+	}
+	return windowBounds{
+		X:         p.Left,
+		Y:         p.Top,
+		Width:     p.Right - p.Left,
+		Height:    p.Bottom - p.Top,
+		Maximized: p.Maximized,
+		WorkArea: screenRect{
+			Left:   p.WorkAreaLeft,
+			Top:    p.WorkAreaTop,
+			Right:  p.WorkAreaRight,
+			Bottom: p.WorkAreaBottom,
+		},
+	}, true
+}
+
+func loadWindowBounds(path string) (windowBounds, bool) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return windowBounds{}, false
+	}
+	var b windowBounds
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return windowBounds{}, false
+	}
+	return b, true
+}
+
+func saveWindowBounds(path string, b windowBounds) error {
+	raw, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(raw, '\n'))
+}
+
+// fit shrinks b to area and moves it inside when it is partly off it.
+// onScreen is false when b is mostly off area; its position is then left
+// as is for the caller to drop.
+func (b windowBounds) fit(area screenRect) (fitted windowBounds, onScreen bool) {
+	b.Width = min(b.Width, area.Right-area.Left)
+	b.Height = min(b.Height, area.Bottom-area.Top)
+	visibleW := min(b.X+b.Width, area.Right) - max(b.X, area.Left)
+	visibleH := min(b.Y+b.Height, area.Bottom) - max(b.Y, area.Top)
+	if visibleW < min(minVisible, b.Width) || visibleH < min(minVisible, b.Height) {
+		return b, false
+	}
+	b.X = max(area.Left, min(b.X, area.Right-b.Width))
+	b.Y = max(area.Top, min(b.Y, area.Bottom-b.Height))
+	return b, true
+}
+
+// flags maps saved bounds to Helium flags, leaving out what o sets itself.
+// The bounds are fitted to the work area they were saved on: the size
+// shrinks to it, a window partly off it is moved inside, and one mostly off
+// it (or saved without a work area) loses its position so Helium places it.
+// That work area may be stale (monitors change while the app is closed), so
+// Run checks the position again on the live screen (see fitToScreen).
+func (b windowBounds) flags(o WindowOptions) []string {
+	flags, _ := b.launchFlags(o)
+	return flags
+}
+
+// launchFlags is flags, also reporting whether a saved position is used.
+func (b windowBounds) launchFlags(o WindowOptions) (flags []string, restoredPos bool) {
+	if b.Width < minRestoredSize || b.Height < minRestoredSize {
+		return nil, false
+	}
+	keepPos := false
+	if area := b.WorkArea; area.valid() {
+		b, keepPos = b.fit(area)
+	}
+	if o.Width == 0 {
+		flags = append(flags, fmt.Sprintf("--window-size=%d,%d", b.Width, b.Height))
+		if b.Maximized {
+			flags = append(flags, "--start-maximized")
+		}
+	}
+	restoredPos = o.Position == nil && keepPos
+	if restoredPos {
+		flags = append(flags, fmt.Sprintf("--window-position=%d,%d", b.X, b.Y))
+	}
+	return flags, restoredPos
+}
+
+// launchFlags are the flags for the next launch: the remembered bounds, then
+// WindowOptions. restoredPos reports that the window is placed at a
+// remembered position.
+func (rs *runState) launchFlags() (flags []string, restoredPos bool) {
+	if rs.boundsFile == "" {
+		return rs.flags, false
+	}
+	b, ok := loadWindowBounds(rs.boundsFile)
+	if !ok {
+		return rs.flags, false
+	}
+	flags, restoredPos = b.launchFlags(rs.window)
+	return append(flags, rs.flags...), restoredPos
+}
+
+// fitToScreen checks a window opened at a remembered position against the
+// screen it actually opened on, and moves it back inside when it is partly
+// off it, or centres it when it is mostly off (a monitor that is gone, or a
+// changed layout). Failures are only logged.
+func (rs *runState) fitToScreen(d *DevTools) {
+	ctx, cancel := context.WithTimeout(rs.ctx, windowBoundsTimeout)
+	defer cancel()
+	area, err := liveWorkArea(ctx, d)
+	if err != nil {
+		rs.log.Debug("screen not checked", "err", err)
+		return
+	}
+	cur, err := d.Bounds(ctx)
+	if err != nil || (cur.State != "" && cur.State != WindowNormal) {
+		return
+	}
+	b := windowBounds{X: cur.Left, Y: cur.Top, Width: cur.Width, Height: cur.Height}
+	fitted, onScreen := b.fit(area)
+	if !onScreen {
+		fitted.X = area.Left + (area.Right-area.Left-fitted.Width)/2
+		fitted.Y = area.Top + (area.Bottom-area.Top-fitted.Height)/2
+	}
+	if fitted == b {
+		return
+	}
+	if err := setNormalBounds(ctx, d, fitted); err != nil {
+		rs.log.Warn("window not moved on screen", "err", err)
+	}
+}
+
+// liveWorkArea is the work area of the screen d's page is on.
+func liveWorkArea(ctx context.Context, d *DevTools) (screenRect, error) {
+	var area screenRect
+	if err := d.Evaluate(ctx, workAreaScript, &area); err != nil {
+		return screenRect{}, err
+	}
+	if !area.valid() {
+		return screenRect{}, fmt.Errorf("no work area: %+v", area)
+	}
+	return area, nil
+}
+
+// setNormalBounds sets all of b's position and size; unlike SetBounds, a
+// zero left or top is sent too.
+func setNormalBounds(ctx context.Context, d *DevTools, b windowBounds) error {
+	id, err := d.windowID(ctx)
+	if err != nil {
+		return err
+	}
+	bounds := map[string]any{"left": b.X, "top": b.Y, "width": b.Width, "height": b.Height}
+	return d.call(ctx, "", "Browser.setWindowBounds", map[string]any{"windowId": id, "bounds": bounds}, nil)
+}
+
+// liveBounds reads the bounds of a window about to be stopped over
+// DevTools: a killed Helium never writes them to the profile. A window
+// that is not in the normal state keeps its remembered restored size.
+func (rs *runState) liveBounds(win *appWindow) (windowBounds, bool) {
+	d := win.devTools()
+	if rs.boundsFile == "" || d == nil {
+		return windowBounds{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), windowBoundsTimeout)
+	defer cancel()
+	cur, err := d.Bounds(ctx)
+	if err != nil {
+		rs.log.Debug("window bounds not read", "err", err)
+		return windowBounds{}, false
+	}
+	b := windowBounds{X: cur.Left, Y: cur.Top, Width: cur.Width, Height: cur.Height}
+	if cur.State != "" && cur.State != WindowNormal {
+		prev, ok := readProfileBounds(rs.profileDir)
+		if !ok {
+			prev, ok = loadWindowBounds(rs.boundsFile)
+		}
+		if !ok {
+			return windowBounds{}, false
+		}
+		b = prev
+		b.Maximized = cur.State == WindowMaximized
+	}
+	if area, err := liveWorkArea(ctx, d); err == nil {
+		b.WorkArea = area
+	}
+	return b, true
+}
+
+// saveBounds copies the window's last bounds to the state dir: the ones
+// stopWindow read over DevTools, else those Helium left in the profile on
+// exit. Failing only costs the next launch its placement, so it is logged.
+func (rs *runState) saveBounds() {
+	if rs.boundsFile == "" {
+		return
+	}
+	rs.mu.Lock()
+	live := rs.stoppedBounds
+	rs.mu.Unlock()
+	b, ok := readProfileBounds(rs.profileDir)
+	if live != nil {
+		b, ok = *live, true
+	}
+	if !ok {
+		return
+	}
+	if err := saveWindowBounds(rs.boundsFile, b); err != nil {
+		rs.log.Warn("window bounds not saved", "err", err)
+	}
+}
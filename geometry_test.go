@@ -0,0 +1,236 @@
+package eletrocromo
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPreferences = `{"browser":{"app_window_placement":{
+	"_crx_other":{"left":0,"top":0,"right":300,"bottom":300},
+	"127.0.0.1_/":{"left":100,"top":50,"right":900,"bottom":650,"maximized":false,
+		"work_area_left":0,"work_area_top":0,"work_area_right":1920,"work_area_bottom":1080}
+}}}`
+
+func writePreferences(t *testing.T, profileDir, prefs string) {
+	t.Helper()
+	dir := filepath.Join(profileDir, "Default")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Preferences"), []byte(prefs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadProfileBounds(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := readProfileBounds(dir); ok {
+		t.Fatal("bounds without Preferences")
+	}
+	writePreferences(t, dir, testPreferences)
+	b, ok := readProfileBounds(dir)
+	want := windowBounds{X: 100, Y: 50, Width: 800, Height: 600, WorkArea: screenRect{Right: 1920, Bottom: 1080}}
+	if !ok || b != want {
+		t.Fatalf("bounds = %+v, %v; want %+v", b, ok, want)
+	}
+
+	writePreferences(t, dir, `{"browser":{"app_window_placement":{"a":{"left":10,"top":10,"right":5,"bottom":5}}}}`)
+	if b, ok := readProfileBounds(dir); ok {
+		t.Fatalf("empty rect accepted: %+v", b)
+	}
+	writePreferences(t, dir, `{"browser":`)
+	if _, ok := readProfileBounds(dir); ok {
+		t.Fatal("corrupt Preferences accepted")
+	}
+}
+
+func TestWindowBounds_Flags(t *testing.T) {
+	area := screenRect{Right: 1920, Bottom: 1080}
+	tests := []struct {
+		name string
+		b    windowBounds
+		o    WindowOptions
+		want []string
+	}{
+		{
+			name: "on screen",
+			b:    windowBounds{X: 100, Y: 50, Width: 800, Height: 600, WorkArea: area},
+			want: []string{"--window-size=800,600", "--window-position=100,50"},
+		},
+		{
+			name: "partly off screen is moved inside",
+			b:    windowBounds{X: 1500, Y: -40, Width: 800, Height: 600, WorkArea: area},
+			want: []string{"--window-size=800,600", "--window-position=1120,0"},
+		},
+		{
+			name: "mostly off screen loses its position",
+			b:    windowBounds{X: 1880, Y: 50, Width: 800, Height: 600, WorkArea: area},
+			want: []string{"--window-size=800,600"},
+		},
+		{
+			name: "on a monitor that is gone",
+			b:    windowBounds{X: 4000, Y: 50, Width: 800, Height: 600, WorkArea: area},
+			want: []string{"--window-size=800,600"},
+		},
+		{
+			name: "larger than the work area",
+			b:    windowBounds{X: 0, Y: 0, Width: 3000, Height: 2000, Maximized: true, WorkArea: area},
+			want: []string{"--window-size=1920,1080", "--start-maximized", "--window-position=0,0"},
+		},
+		{
+			name: "no work area",
+			b:    windowBounds{X: 100, Y: 50, Width: 800, Height: 600},
+			want: []string{"--window-size=800,600"},
+		},
+		{
+			name: "too small",
+			b:    windowBounds{X: 100, Y: 50, Width: 20, Height: 600, WorkArea: area},
+		},
+		{
+			name: "options win",
+			b:    windowBounds{X: 100, Y: 50, Width: 800, Height: 600, WorkArea: area},
+			o:    WindowOptions{Width: 420, Height: 720},
+			want: []string{"--window-position=100,50"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.b.flags(tt.o); !slices.Equal(got, tt.want) {
+				t.Fatalf("flags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRun_RemembersWindowBounds: bounds Helium leaves in the profile on exit
+// come back as flags on the next launch.
+func TestRun_RemembersWindowBounds(t *testing.T) {
+	dir := t.TempDir()
+	launches := filepath.Join(dir, "launches")
+	script := filepath.Join(dir, "fake-helium")
+	body := `#!/bin/sh
+echo launch "$@" >> ` + launches + `
+for a in "$@"; do
+	case "$a" in --user-data-dir=*) profile="${a#--user-data-dir=}" ;; esac
+done
+sleep 0.3
+mkdir -p "$profile/Default"
+cat > "$profile/Default/Preferences" <<'EOF'
+` + testPreferences + `
+EOF
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	host := scriptHost(t, script)
+
+	run := func(o WindowOptions) []string {
+		t.Helper()
+		if err := os.Remove(launches); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		app := New(http.NotFoundHandler(),
+			WithID("br.tec.lew.test.window_bounds"),
+			WithContext(t.Context()),
+			WithBrowserHost(host),
+			WithWindow(o),
+		)
+		if err := app.Run(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(launches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Fields(string(b))
+	}
+
+	if argv := run(WindowOptions{}); slices.ContainsFunc(argv, func(a string) bool {
+		return strings.HasPrefix(a, "--window-")
+	}) {
+		t.Fatalf("first launch argv = %q", argv)
+	}
+	argv := run(WindowOptions{})
+	if !slices.Contains(argv, "--window-size=800,600") || !slices.Contains(argv, "--window-position=100,50") {
+		t.Fatalf("second launch argv = %q", argv)
+	}
+	argv = run(WindowOptions{NoRestoreBounds: true})
+	if slices.Contains(argv, "--window-size=800,600") {
+		t.Fatalf("NoRestoreBounds argv = %q", argv)
+	}
+}
+
+// TestRun_FitsRestoredWindowToLiveScreen: the saved work area is stale (the
+// window was on a wider screen), so the opened window, mostly off the screen
+// it is on, is centred there; on shutdown the bounds are read over DevTools before the
+// window is killed.
+func TestRun_FitsRestoredWindowToLiveScreen(t *testing.T) {
+	fakeHostEnv(t)
+	host := &fakeHost{bin: "helium", t: t, browser: func(req cdpRequest, params map[string]any) (any, string) {
+		switch req.Method {
+		case "Runtime.evaluate":
+			return map[string]any{"result": map[string]any{"type": "object", "value": map[string]any{
+				"left": 0, "top": 0, "right": 1280, "bottom": 800,
+			}}}, ""
+		case "Browser.getWindowBounds":
+			return map[string]any{"bounds": map[string]any{"left": 1500, "top": 50, "width": 800, "height": 600, "windowState": "normal"}}, ""
+		}
+		return appWindowBrowser(req, params)
+	}}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.window_fit"),
+		WithContext(ctx),
+		WithBrowserHost(host),
+	)
+	boundsFile := app.windowBoundsFile()
+	if err := saveWindowBounds(boundsFile, windowBounds{
+		X: 1500, Y: 50, Width: 800, Height: 600, WorkArea: screenRect{Right: 2560, Bottom: 1440},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+
+	var moved cdpRequest
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		host.mu.Lock()
+		var fb *fakeBrowser
+		if len(host.browsers) > 0 {
+			fb = host.browsers[0]
+		}
+		host.mu.Unlock()
+		if fb != nil {
+			if req, ok := fb.find("Browser.setWindowBounds"); ok {
+				moved = req
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if moved.Method == "" {
+		t.Fatal("window was not moved onto the live screen")
+	}
+	got := moved.Params.(map[string]any)["bounds"].(map[string]any)
+	if got["left"] != float64(240) || got["top"] != float64(100) || got["width"] != float64(800) {
+		t.Fatalf("setWindowBounds bounds = %v, want 800x600 centred at 240,100", got)
+	}
+
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	saved, ok := loadWindowBounds(boundsFile)
+	want := windowBounds{X: 1500, Y: 50, Width: 800, Height: 600, WorkArea: screenRect{Right: 1280, Bottom: 800}}
+	if !ok || saved != want {
+		t.Fatalf("saved bounds = %+v, want the live ones %+v", saved, want)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/lewtec/eletrocromo
 go 1.25.6
 
 require (
+	github.com/godbus/dbus/v5 v5.2.2
 	github.com/google/uuid v1.6.0
 	github.com/lucasew/workspaced v0.0.0-20260722123058-736cf5ffa93a
 	github.com/spf13/cobra v1.10.2
@@ -0,0 +1,64 @@
+package eletrocromo
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// setBoundHost records the host:port Run is serving on; from then on the
+// gate only accepts that Host (plus AllowedHosts). Empty clears it.
+func (a *App) setBoundHost(hostport string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.boundHost = strings.ToLower(hostport)
+}
+
+// hostAllowed rejects DNS-rebound names: a page on evil.example resolving to
+// 127.0.0.1 reaches the port, but its requests carry Host: evil.example:PORT.
+// Outside Run there is no bound address to compare against, so any Host passes.
+func (a *App) hostAllowed(host string) bool {
+	a.mu.Lock()
+	bound := a.boundHost
+	a.mu.Unlock()
+	if bound == "" {
+		return true
+	}
+	host = strings.ToLower(host)
+	if host == bound {
+		return true
+	}
+	for _, h := range a.AllowedHosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if host == h {
+			return true
+		}
+		// A bare allowed name matches on any port.
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			if name, _, err := net.SplitHostPort(host); err == nil && name == h {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// crossOrigin rejects state-changing requests that the browser marks as
+// cross-site (Sec-Fetch-Site) or whose Origin differs from Host. Safe
+// methods and non-browser clients (no such headers) pass.
+var crossOrigin = http.NewCrossOriginProtection()
+
+// guardRequest is the pre-auth gate: Host must be ours and unsafe requests
+// must be same-origin. Returns the refusal reason, or "".
+func (a *App) guardRequest(r *http.Request) AuthReason {
+	if !a.hostAllowed(r.Host) {
+		return AuthBadHost
+	}
+	if crossOrigin.Check(r) != nil {
+		return AuthCrossOrigin
+	}
+	return ""
+}
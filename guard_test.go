@@ -0,0 +1,79 @@
+package eletrocromo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTP_HostAndOriginGate(t *testing.T) {
+	const bound = "127.0.0.1:4242"
+	tests := []struct {
+		name    string
+		method  string
+		host    string
+		allowed []string
+		headers map[string]string
+		want    int
+	}{
+		{name: "bound host", method: http.MethodGet, host: bound, want: http.StatusOK},
+		{name: "rebound hostname", method: http.MethodGet, host: "evil.example:4242", want: http.StatusForbidden},
+		{name: "localhost is not the bound address", method: http.MethodGet, host: "localhost:4242", want: http.StatusForbidden},
+		{name: "other port", method: http.MethodGet, host: "127.0.0.1:4343", want: http.StatusForbidden},
+		{name: "allowed bare name any port", method: http.MethodGet, host: "myapp.localhost:4242", allowed: []string{"myapp.localhost"}, want: http.StatusOK},
+		{name: "allowed host:port", method: http.MethodGet, host: "LOCALHOST:4242", allowed: []string{"localhost:4242"}, want: http.StatusOK},
+		{name: "allowed host:port wrong port", method: http.MethodGet, host: "localhost:1", allowed: []string{"localhost:4242"}, want: http.StatusForbidden},
+		{name: "same-origin POST", method: http.MethodPost, host: bound,
+			headers: map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://" + bound}, want: http.StatusOK},
+		{name: "cross-site POST", method: http.MethodPost, host: bound,
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+		{name: "foreign Origin POST", method: http.MethodPost, host: bound,
+			headers: map[string]string{"Origin": "http://evil.example"}, want: http.StatusForbidden},
+		{name: "foreign Origin GET is safe", method: http.MethodGet, host: bound,
+			headers: map[string]string{"Origin": "http://evil.example"}, want: http.StatusOK},
+		{name: "non-browser POST", method: http.MethodPost, host: bound, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				AuthToken:    "secret-token",
+				AllowedHosts: tt.allowed,
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}),
+			}
+			app.setBoundHost(bound)
+			req := newAuthRequest(tt.method, "/", "", "secret-token")
+			req.Host = tt.host
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusForbidden && w.Body.String() != "forbidden" {
+				t.Fatalf("body = %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTP_GateRunsBeforeBootstrap(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler()}
+	app.setBoundHost("127.0.0.1:4242")
+	nonce := app.mintBootstrap()
+
+	req := httptest.NewRequest(http.MethodGet, "/?token="+nonce, nil)
+	req.Host = "evil.example:4242"
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("rebound bootstrap: want 403, got %d", w.Code)
+	}
+	// The rejected request must not have consumed the token.
+	if !app.redeemBootstrap(nonce) {
+		t.Fatal("bootstrap token was spent by a rejected request")
+	}
+}
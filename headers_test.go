@@ -0,0 +1,84 @@
+package eletrocromo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveWithHeaders(t *testing.T, app *App) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	var nonce string
+	app.AuthToken = "secret-token"
+	app.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodGet, "/", "", "secret-token"))
+	return w, nonce
+}
+
+func TestSecurityHeaders_DefaultsAndNonce(t *testing.T) {
+	w, nonce := serveWithHeaders(t, &App{})
+	if nonce == "" {
+		t.Fatal("CSPNonce empty inside the handler")
+	}
+	csp := w.Header().Get("Content-Security-Policy")
+	for _, want := range []string{"'nonce-" + nonce + "'", "frame-ancestors 'none'", "default-src 'self'"} {
+		if !strings.Contains(csp, want) {
+			t.Fatalf("CSP %q missing %q", csp, want)
+		}
+	}
+	if strings.Contains(csp, CSPNoncePlaceholder) {
+		t.Fatalf("placeholder left in CSP: %q", csp)
+	}
+	for name, want := range map[string]string{
+		"X-Content-Type-Options":     "nosniff",
+		"Referrer-Policy":            "no-referrer",
+		"Cross-Origin-Opener-Policy": "same-origin",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	_, other := serveWithHeaders(t, &App{})
+	if other == nonce {
+		t.Fatal("nonce reused across requests")
+	}
+}
+
+func TestSecurityHeaders_OverrideAndDisable(t *testing.T) {
+	app := New(nil,
+		WithSecurityHeader("content-security-policy", "default-src 'self'; script-src 'nonce-{nonce}'"),
+		WithSecurityHeader("Cross-Origin-Opener-Policy", ""),
+		WithSecurityHeader("Permissions-Policy", "camera=()"),
+	)
+	w, nonce := serveWithHeaders(t, app)
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'self'; script-src 'nonce-"+nonce+"'" {
+		t.Fatalf("overridden CSP = %q", got)
+	}
+	if _, ok := w.Header()["Cross-Origin-Opener-Policy"]; ok {
+		t.Fatal("disabled header still set")
+	}
+	if got := w.Header().Get("Permissions-Policy"); got != "camera=()" {
+		t.Fatalf("added header = %q", got)
+	}
+	if got := w.Header().Get("Referrer-Policy"); got != "no-referrer" {
+		t.Fatalf("untouched default lost: %q", got)
+	}
+}
+
+func TestSecurityHeaders_HandlerCanOverride(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Referrer-Policy", "same-origin")
+		w.WriteHeader(http.StatusOK)
+	})}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodGet, "/", "", "secret-token"))
+	if got := w.Header().Get("Referrer-Policy"); got != "same-origin" {
+		t.Fatalf("handler override lost: %q", got)
+	}
+}
@@ -0,0 +1,246 @@
+package eletrocromo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyRunning is returned when another process holds the App.ID instance
+// lock and the resume request could not be delivered to it.
+var ErrAlreadyRunning = errors.New("another instance of this app is running")
+
+// ErrInstanceUnsafe is returned by Run when the instance dir, lock file or
+// control socket is not private to this user: another local user could
+// redirect the lock's writes or answer the socket in our place.
+var ErrInstanceUnsafe = errors.New("single-instance files are not private to this user")
+
+// errInstanceUnsupported is returned by acquireInstance where there is no
+// working instance lock (Windows); Run then skips single-instance.
+var errInstanceUnsupported = errors.New("single-instance lock not supported on this platform")
+
+// resumeFocusTimeout bounds focusing the open window for a second launch.
+var resumeFocusTimeout = 5 * time.Second
+
+// instanceDialWindow is how long a second launch retries the control socket:
+// the primary takes the lock before it starts listening.
+var instanceDialWindow = 3 * time.Second
+
+// ResumeRequest is what a second launch forwards to the running instance.
+type ResumeRequest struct {
+	// Args is the second process's os.Args[1:].
+	Args []string `json:"args"`
+	// Dir is the second process's working directory.
+	Dir string `json:"dir"`
+}
+
+// resumeReply is the control socket's answer to a ResumeRequest.
+type resumeReply struct {
+	PID   int    `json:"pid"`
+	Error string `json:"error,omitempty"`
+}
+
+// instance is the primary's hold on the per-App.ID lock and control socket.
+// The lock is an OS file lock, so a crashed run never leaves it held; only
+// the lock file (stale PID) and socket file remain, and both are reused.
+type instance struct {
+	lock *os.File
+	ln   net.Listener
+	sock string
+}
+
+// instancePaths returns the lock file and control socket for appID under
+// $XDG_RUNTIME_DIR/eletrocromo (or a per-user temp dir). The dirs must be
+// ours and 0700: the temp dir is shared, and another user may have created
+// the per-user one first.
+func instancePaths(appID string) (lockPath, sockPath string, err error) {
+	if err := ValidateAppID(appID); err != nil {
+		return "", "", err
+	}
+	var private []string
+	dir := strings.TrimSpace(os.Getenv("XDG_RUNTIME_DIR"))
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "eletrocromo-"+strconv.Itoa(os.Getuid()))
+		private = append(private, dir)
+	}
+	dir = filepath.Join(dir, "eletrocromo")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", fmt.Errorf("instance dir: %w", err)
+	}
+	for _, p := range append(private, dir) {
+		if err := checkPrivateDir(p); err != nil {
+			return "", "", err
+		}
+	}
+	return filepath.Join(dir, appID+".lock"), filepath.Join(dir, appID+".sock"), nil
+}
+
+// acquireInstance takes the App.ID lock and opens the control socket.
+// Returns ErrAlreadyRunning (unwrapped) when a live process holds the lock,
+// and errInstanceUnsupported without touching the socket where no lock can
+// be taken: without the lock a second run would replace the primary's socket.
+func acquireInstance(appID string) (*instance, error) {
+	if !instanceLockSupported {
+		return nil, errInstanceUnsupported
+	}
+	lockPath, sockPath, err := instancePaths(appID)
+	if err != nil {
+		return nil, err
+	}
+	// No symlinks: the PID write below truncates whatever the path names.
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|openNoFollow, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("instance lock: %w", err)
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() || !ownedByUser(fi) {
+		_ = f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrInstanceUnsafe, lockPath)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	// Record our PID for humans/diagnostics; a stale PID from a crash is
+	// simply overwritten.
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	// We hold the lock, so any socket file left behind is stale, unless
+	// someone else put it there.
+	if fi, err := os.Lstat(sockPath); err == nil && !ownedByUser(fi) {
+		_ = f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrInstanceUnsafe, sockPath)
+	}
+	removeBestEffort(sockPath)
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("instance socket: %w", err)
+	}
+	return &instance{lock: f, ln: ln, sock: sockPath}, nil
+}
+
+// serve answers resume requests until close. handle runs per request.
+func (in *instance) serve(handle func(ResumeRequest) error) {
+	go func() {
+		for {
+			c, err := in.ln.Accept()
+			if err != nil {
+				return
+			}
+			go in.handleConn(c, handle)
+		}
+	}()
+}
+
+func (in *instance) handleConn(c net.Conn, handle func(ResumeRequest) error) {
+	defer func() { _ = c.Close() }()
+	if err := c.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return
+	}
+	var req ResumeRequest
+	if err := json.NewDecoder(bufio.NewReader(c)).Decode(&req); err != nil {
+		return
+	}
+	reply := resumeReply{PID: os.Getpid()}
+	if err := handle(req); err != nil {
+		reply.Error = err.Error()
+	}
+	if err := json.NewEncoder(c).Encode(reply); err != nil {
+		return
+	}
+}
+
+func (in *instance) close() {
+	if in == nil {
+		return
+	}
+	_ = in.ln.Close()
+	removeBestEffort(in.sock)
+	// Closing the descriptor releases the lock; the file stays for reuse.
+	_ = in.lock.Close()
+}
+
+// resumeInstance forwards req to the running instance of appID and returns
+// its PID. The primary's handler error (if any) is returned wrapped.
+func resumeInstance(ctx context.Context, appID string, req ResumeRequest) (int, error) {
+	_, sockPath, err := instancePaths(appID)
+	if err != nil {
+		return 0, err
+	}
+	var d net.Dialer
+	var c net.Conn
+	deadline := time.Now().Add(instanceDialWindow)
+	for {
+		c, err = d.DialContext(ctx, "unix", sockPath)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil || !time.Now().Before(deadline) {
+			return 0, fmt.Errorf("%w: control socket: %w", ErrAlreadyRunning, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer func() { _ = c.Close() }()
+	if err := c.SetDeadline(time.Now().Add(15 * time.Second)); err != nil {
+		return 0, err
+	}
+	if err := json.NewEncoder(c).Encode(req); err != nil {
+		return 0, fmt.Errorf("%w: send resume: %w", ErrAlreadyRunning, err)
+	}
+	var reply resumeReply
+	if err := json.NewDecoder(c).Decode(&reply); err != nil {
+		return 0, fmt.Errorf("%w: read resume reply: %w", ErrAlreadyRunning, err)
+	}
+	if reply.Error != "" {
+		return reply.PID, fmt.Errorf("running instance (pid %d): %s", reply.PID, reply.Error)
+	}
+	return reply.PID, nil
+}
+
+// currentResumeRequest describes this process for the running instance.
+func currentResumeRequest() ResumeRequest {
+	req := ResumeRequest{Args: []string{}}
+	if len(os.Args) > 1 {
+		req.Args = append(req.Args, os.Args[1:]...)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		req.Dir = wd
+	}
+	return req
+}
+
+// handleResume is the primary side: tell the app, then bring the open window
+// forward (DevTools) or reopen it. A request that lands while the primary is
+// still starting needs no window action: the primary opens its window on its
+// own.
+func (a *App) handleResume(req ResumeRequest) error {
+	lg := a.logger(phaseInstance)
+	lg.Info("resume request from second launch", "args", req.Args, "dir", req.Dir)
+	if a.OnResume != nil {
+		a.OnResume(req)
+	}
+	if d, err := a.DevTools(); err == nil {
+		ctx, cancel := context.WithTimeout(background, resumeFocusTimeout)
+		defer cancel()
+		err := d.Focus(ctx)
+		if err == nil {
+			return nil
+		}
+		// Most likely closed meanwhile: reopen below.
+		lg.Warn("focus window failed", "err", err)
+	}
+	err := a.OpenWindow()
+	if errors.Is(err, ErrNoWindow) || errors.Is(err, ErrNotRunning) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package eletrocromo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// instanceLockSupported: flock gives single-instance a real lock.
+const instanceLockSupported = true
+
+// openNoFollow makes opening the lock file fail on a symlink.
+const openNoFollow = syscall.O_NOFOLLOW
+
+// ownedByUser reports whether fi belongs to this process's user.
+func ownedByUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
+
+// checkPrivateDir requires path to be a real directory (not a symlink) that
+// we own with mode 0700.
+func checkPrivateDir(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("instance dir: %w", err)
+	}
+	if !fi.IsDir() || !ownedByUser(fi) || fi.Mode().Perm() != 0o700 {
+		return fmt.Errorf("%w: %s is %s", ErrInstanceUnsafe, path, fi.Mode())
+	}
+	return nil
+}
+
+// lockFile takes a non-blocking exclusive flock. The kernel drops it when the
+// holder exits (even on crash), so stale locks cannot block a new run.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrAlreadyRunning
+	}
+	if err != nil {
+		return fmt.Errorf("instance lock: %w", err)
+	}
+	return nil
+}
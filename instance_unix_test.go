@@ -0,0 +1,252 @@
+//go:build unix
+
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInstance_SecondAcquireForwardsResume(t *testing.T) {
+	shortRuntimeDir(t)
+	const id = "br.tec.lew.test.instance"
+
+	first, err := acquireInstance(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.close()
+	got := make(chan ResumeRequest, 1)
+	first.serve(func(req ResumeRequest) error {
+		got <- req
+		return nil
+	})
+
+	if _, err := acquireInstance(id); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("second acquire: want ErrAlreadyRunning, got %v", err)
+	}
+	pid, err := resumeInstance(t.Context(), id, ResumeRequest{Args: []string{"open", "a.txt"}, Dir: "/work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != os.Getpid() {
+		t.Fatalf("pid = %d, want %d", pid, os.Getpid())
+	}
+	req := <-got
+	if !slices.Equal(req.Args, []string{"open", "a.txt"}) || req.Dir != "/work" {
+		t.Fatalf("forwarded %+v", req)
+	}
+
+	// Releasing the lock lets the next run become primary.
+	first.close()
+	again, err := acquireInstance(id)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	again.close()
+}
+
+func TestInstance_RecoversStaleLockAndSocket(t *testing.T) {
+	shortRuntimeDir(t)
+	const id = "br.tec.lew.test.stale"
+	lockPath, sockPath, err := instancePaths(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// What a crashed run leaves behind: a lock file with a dead PID (no flock
+	// held) and the socket path still on disk.
+	if err := os.WriteFile(lockPath, []byte("999999\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sockPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := acquireInstance(id)
+	if err != nil {
+		t.Fatalf("acquire over stale files: %v", err)
+	}
+	defer in.close()
+	in.serve(func(ResumeRequest) error { return nil })
+	if _, err := resumeInstance(t.Context(), id, ResumeRequest{}); err != nil {
+		t.Fatalf("resume after recovery: %v", err)
+	}
+}
+
+func TestInstance_NoLiveInstance(t *testing.T) {
+	shortRuntimeDir(t)
+	orig := instanceDialWindow
+	instanceDialWindow = 100 * time.Millisecond
+	t.Cleanup(func() { instanceDialWindow = orig })
+
+	_, err := resumeInstance(t.Context(), "br.tec.lew.test.nobody", ResumeRequest{})
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("want ErrAlreadyRunning, got %v", err)
+	}
+}
+
+func TestRun_SecondLaunchReopensPrimaryWindow(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+	const id = "br.tec.lew.test.single"
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	resumed := make(chan ResumeRequest, 1)
+	primary := New(http.NotFoundHandler(),
+		WithID(id),
+		WithContext(ctx),
+		WithBrowserHost(host),
+		WithBackground(true),
+		WithOnResume(func(req ResumeRequest) { resumed <- req }),
+	)
+	errCh := make(chan error, 1)
+	go func() { errCh <- primary.Run() }()
+
+	// Let the first window come and go; the primary keeps serving.
+	deadline := time.Now().Add(5 * time.Second)
+	for countLaunches(t, launches) < 1 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	second := New(http.NotFoundHandler(), WithID(id), WithContext(t.Context()))
+	if err := second.Run(); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	select {
+	case <-resumed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("primary did not receive the resume request")
+	}
+	if n := countLaunches(t, launches); n != 2 {
+		t.Fatalf("launches = %d, want 2 (second launch reopens the primary window)", n)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun_SecondLaunchFocusesOpenWindow(t *testing.T) {
+	fakeHostEnv(t)
+	host := &fakeHost{bin: "helium", browser: appWindowBrowser, t: t}
+	const id = "br.tec.lew.test.single_focus"
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	opened := make(chan int, 1)
+	primary := New(http.NotFoundHandler(),
+		WithID(id),
+		WithContext(ctx),
+		WithBrowserHost(host),
+		WithOnWindowOpened(func(pid int) { opened <- pid }),
+	)
+	errCh := make(chan error, 1)
+	go func() { errCh <- primary.Run() }()
+	select {
+	case <-opened:
+	case err := <-errCh:
+		t.Fatalf("Run returned early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("window never opened")
+	}
+
+	second := New(http.NotFoundHandler(), WithID(id), WithContext(t.Context()))
+	if err := second.Run(); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	host.mu.Lock()
+	launches, fb := len(host.launches), host.browsers[0]
+	host.mu.Unlock()
+	if launches != 1 {
+		t.Fatalf("launches = %d, want 1 (the open window is reused)", launches)
+	}
+	if req, ok := fb.find("Target.activateTarget"); !ok || req.Params.(map[string]any)["targetId"] != "PAGE" {
+		t.Fatalf("open window not focused: %+v", fb.requests())
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstance_RefusesSharedDir(t *testing.T) {
+	dir := shortRuntimeDir(t)
+	if err := os.Mkdir(filepath.Join(dir, "eletrocromo"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "eletrocromo"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireInstance("br.tec.lew.test.shared"); !errors.Is(err, ErrInstanceUnsafe) {
+		t.Fatalf("want ErrInstanceUnsafe, got %v", err)
+	}
+}
+
+func TestInstance_RefusesSymlinkedTempDir(t *testing.T) {
+	tmp := shortRuntimeDir(t)
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", tmp)
+	target := t.TempDir()
+	if err := os.Symlink(target, filepath.Join(tmp, "eletrocromo-"+strconv.Itoa(os.Getuid()))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireInstance("br.tec.lew.test.symdir"); !errors.Is(err, ErrInstanceUnsafe) {
+		t.Fatalf("want ErrInstanceUnsafe, got %v", err)
+	}
+}
+
+func TestInstance_LockDoesNotFollowSymlink(t *testing.T) {
+	shortRuntimeDir(t)
+	const id = "br.tec.lew.test.symlock"
+	lockPath, _, err := instancePaths(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(victim, []byte("keep me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(victim, lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := acquireInstance(id); err == nil {
+		in.close()
+		t.Fatal("acquired the lock through a symlink")
+	}
+	if b, err := os.ReadFile(victim); err != nil || string(b) != "keep me" {
+		t.Fatalf("symlink target was written: %q, %v", b, err)
+	}
+}
+
+func TestInstance_RefusesForeignSocket(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("needs root to give the socket path another owner")
+	}
+	shortRuntimeDir(t)
+	const id = "br.tec.lew.test.foreign_sock"
+	_, sockPath, err := instancePaths(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sockPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Lchown(sockPath, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := acquireInstance(id); !errors.Is(err, ErrInstanceUnsafe) {
+		in.close()
+		t.Fatalf("want ErrInstanceUnsafe, got %v", err)
+	}
+}
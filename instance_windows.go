@@ -0,0 +1,22 @@
+//go:build windows
+
+package eletrocromo
+
+import "os"
+
+// instanceLockSupported is false on Windows: single-instance is Linux-first
+// (SPEC), so every run acts as the primary there and leaves other runs'
+// control sockets alone.
+const instanceLockSupported = false
+
+// openNoFollow, ownedByUser and checkPrivateDir are unused on Windows.
+const openNoFollow = 0
+
+func ownedByUser(os.FileInfo) bool { return true }
+
+func checkPrivateDir(string) error { return nil }
+
+// lockFile is never reached on Windows (see instanceLockSupported).
+func lockFile(*os.File) error {
+	return errInstanceUnsupported
+}
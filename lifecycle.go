@@ -0,0 +1,35 @@
+package eletrocromo
+
+// ShutdownPhase is passed to App.OnShutdown.
+type ShutdownPhase int
+
+const (
+	// ShutdownStarted: Run's context is done; the window is being closed and
+	// the server drained.
+	ShutdownStarted ShutdownPhase = iota
+	// ShutdownCompleted: the server is closed and the task wait is over,
+	// right before Run returns.
+	ShutdownCompleted
+)
+
+func (p ShutdownPhase) String() string {
+	switch p {
+	case ShutdownStarted:
+		return "started"
+	case ShutdownCompleted:
+		return "completed"
+	}
+	return "unknown"
+}
+
+func (a *App) emitReady(url string) {
+	if a.OnReady != nil {
+		a.OnReady(url)
+	}
+}
+
+func (a *App) emitShutdown(phase ShutdownPhase) {
+	if a.OnShutdown != nil {
+		a.OnShutdown(phase)
+	}
+}
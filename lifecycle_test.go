@@ -0,0 +1,120 @@
+package eletrocromo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookRecorder collects lifecycle hooks in call order.
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *hookRecorder) add(format string, args ...any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, fmt.Sprintf(format, args...))
+}
+
+func (h *hookRecorder) snapshot() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.events...)
+}
+
+func (h *hookRecorder) options() []Option {
+	return []Option{
+		WithOnReady(func(url string) {
+			if strings.Contains(url, "token") {
+				h.add("ready with secret %s", url)
+				return
+			}
+			h.add("ready")
+		}),
+		WithOnWindowOpened(func(pid int) {
+			if pid <= 0 {
+				h.add("opened bad pid %d", pid)
+				return
+			}
+			h.add("opened")
+		}),
+		WithOnWindowClosed(func(err error) { h.add("closed %v", err) }),
+		WithOnShutdown(func(p ShutdownPhase) { h.add("shutdown %s", p) }),
+	}
+}
+
+func TestLifecycle_WindowOwned(t *testing.T) {
+	script, _ := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+
+	var rec hookRecorder
+	app := New(http.NotFoundHandler(), append(rec.options(),
+		WithID("br.tec.lew.test.hooks_window"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+	)...)
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ready", "opened", "closed <nil>", "shutdown started", "shutdown completed"}
+	if got := rec.snapshot(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("hooks = %q, want %q", got, want)
+	}
+}
+
+func TestLifecycle_CancelClosesWindowBeforeCompleted(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "30")
+	host := scriptHost(t, script)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	var rec hookRecorder
+	app := New(http.NotFoundHandler(), append(rec.options(),
+		WithID("br.tec.lew.test.hooks_cancel"),
+		WithContext(ctx),
+		WithBrowserHost(host),
+	)...)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	deadline := time.Now().Add(3 * time.Second)
+	for len(rec.snapshot()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if countLaunches(t, launches) != 1 {
+		t.Fatal("window did not launch")
+	}
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	// Killing the window on shutdown is not reported as a crash.
+	want := []string{"ready", "opened", "shutdown started", "closed <nil>", "shutdown completed"}
+	if got := rec.snapshot(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("hooks = %q, want %q", got, want)
+	}
+}
+
+func TestLifecycle_NoUI(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	var rec hookRecorder
+	app := New(http.NotFoundHandler(), append(rec.options(),
+		WithID("br.tec.lew.test.hooks_noui"),
+		WithContext(ctx),
+	)...)
+	_, errCh := runNoUI(t, app)
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ready", "shutdown started", "shutdown completed"}
+	if got := rec.snapshot(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("hooks = %q, want %q", got, want)
+	}
+}
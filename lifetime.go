@@ -0,0 +1,200 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// BackgroundFlagName is the standard CLI flag registered by BindFlags.
+const BackgroundFlagName = "background"
+
+// ErrNotRunning is returned by OpenWindow when Run is not active.
+var ErrNotRunning = errors.New("app is not running")
+
+// ErrNoWindow is returned by OpenWindow in NoUI mode (no Helium to launch).
+var ErrNoWindow = errors.New("app has no window (NoUI)")
+
+// runState is the live part of a Run that OpenWindow needs: the resolved
+// Helium binary, profile, launch URL minting, and the currently open window.
+type runState struct {
+	ctx        context.Context
+	host       BrowserHost
+	bin        string
+	profileDir string
+	mintLink   func() string // launch URL with a fresh bootstrap token
+	background bool
+	cancel     context.CancelFunc
+	onOpened   func(pid int)
+	onClosed   func(err error)
+	onTarget   func(TargetEvent)
+	flags      []string // WindowOptions.flags
+	window     WindowOptions
+	boundsFile string // remembered bounds; "" when not remembered
+	log        *slog.Logger
+
+	// launchMu serializes launches so two OpenWindow calls cannot race two
+	// Helium processes onto the same profile.
+	launchMu sync.Mutex
+
+	mu      sync.Mutex
+	win     *appWindow    // nil when no window is open
+	winDone chan struct{} // closed once win's exit is handled
+	closed  bool          // set by stopWindow; no launches after shutdown
+	// stoppedBounds are the bounds stopWindow read before killing the
+	// window; saveBounds prefers them to the profile's.
+	stoppedBounds *windowBounds
+}
+
+// BindFlags registers the standard eletrocromo flags on fs (flag.CommandLine
+// when nil). Call before fs.Parse:
+//
+//	-background  keep running after the window closes (see App.Background)
+func (a *App) BindFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	fs.BoolVar(&a.Background, BackgroundFlagName, a.Background,
+		"keep running after the app window closes (reopen with OpenWindow / tray)")
+}
+
+// OpenWindow (re)launches the Helium --app window against the live server
+// and token, without restarting anything. If the window is still open it is
+// left as is. Returns ErrNotRunning outside Run and ErrNoWindow in NoUI mode.
+func (a *App) OpenWindow() error {
+	a.mu.Lock()
+	rs := a.run
+	a.mu.Unlock()
+	if rs == nil {
+		return ErrNotRunning
+	}
+	if rs.bin == "" {
+		return ErrNoWindow
+	}
+	return rs.openWindow()
+}
+
+func (a *App) setRunState(rs *runState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.run = rs
+}
+
+// backgroundMode resolves Background: WithBackground, then App.Background
+// (or -background), then ELETROCROMO_BACKGROUND.
+func (a *App) backgroundMode() bool {
+	if a.background != nil {
+		return *a.background
+	}
+	return a.Background || backgroundEnabled()
+}
+
+func backgroundEnabled() bool {
+	return envTruthy("ELETROCROMO_BACKGROUND")
+}
+
+// openWindow launches Helium unless a window is already open. A launch that
+// dies during the startup grace is an error (ErrHeliumLaunch).
+func (rs *runState) openWindow() error {
+	rs.launchMu.Lock()
+	defer rs.launchMu.Unlock()
+
+	rs.mu.Lock()
+	open, closed := rs.win != nil, rs.closed
+	rs.mu.Unlock()
+	if closed {
+		return ErrNotRunning
+	}
+	if open {
+		return nil
+	}
+
+	flags, restoredPos := rs.launchFlags()
+	bw, err := rs.host.Launch(rs.ctx, rs.bin, LaunchSpec{
+		URL:        rs.mintLink(),
+		ProfileDir: rs.profileDir,
+		Flags:      flags,
+	})
+	if err != nil {
+		return fmt.Errorf("launch Helium: %w", err)
+	}
+	win := newAppWindow(bw)
+	if err := win.awaitStartup(heliumStartupGrace); err != nil {
+		win.stop()
+		return err
+	}
+	if d := win.devTools(); d != nil {
+		d.start(rs.onTarget)
+		if restoredPos {
+			go rs.fitToScreen(d)
+		}
+	}
+	done := make(chan struct{})
+	rs.mu.Lock()
+	rs.win = win
+	rs.winDone = done
+	rs.mu.Unlock()
+	if rs.onOpened != nil {
+		rs.onOpened(win.pid())
+	}
+
+	win.watchExit(func(exitErr error) {
+		defer close(done)
+		rs.mu.Lock()
+		if rs.win == win {
+			rs.win = nil
+		}
+		killed := rs.closed
+		rs.mu.Unlock()
+		rs.saveBounds()
+		if exitErr != nil {
+			rs.log.Warn("Helium exited", "err", exitErr)
+		} else {
+			rs.log.Info("Helium exited")
+		}
+		if rs.onClosed != nil {
+			if killed {
+				// Our own teardown, not a crash.
+				exitErr = nil
+			}
+			rs.onClosed(exitErr)
+		}
+		if rs.background {
+			// Background lifetime: server and tasks outlive the window.
+			rs.log.Info("background mode: still serving; reopen with OpenWindow")
+			return
+		}
+		// Window-owned lifetime: window dies ⇒ app dies.
+		rs.cancel()
+	})
+	return nil
+}
+
+// stopWindow tears down the current window's process tree, if any, waits
+// for its exit to be handled (OnWindowClosed) and refuses further launches
+// (Run is shutting down).
+func (rs *runState) stopWindow() {
+	rs.launchMu.Lock()
+	defer rs.launchMu.Unlock()
+	rs.mu.Lock()
+	win, done := rs.win, rs.winDone
+	rs.win = nil
+	rs.closed = true
+	rs.mu.Unlock()
+	if win != nil {
+		if b, ok := rs.liveBounds(win); ok {
+			rs.mu.Lock()
+			rs.stoppedBounds = &b
+			rs.mu.Unlock()
+		}
+	}
+	win.stop()
+	if done != nil {
+		// Also covers a window that exited just before shutdown whose exit
+		// handler is still running.
+		<-done
+	}
+}
@@ -0,0 +1,162 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHeliumScript writes a shell script that records each launch (and its
+// argv) in launches and stays up for life (sleep argument).
+func fakeHeliumScript(t *testing.T, life string) (script, launches string) {
+	t.Helper()
+	dir := t.TempDir()
+	launches = filepath.Join(dir, "launches")
+	script = filepath.Join(dir, "fake-helium")
+	body := "#!/bin/sh\necho launch \"$@\" >> " + launches + "\nexec sleep " + life + "\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, launches
+}
+
+func countLaunches(t *testing.T, path string) int {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(b), "\n")
+}
+
+// scriptHost is the default host pinned to bin (a fakeHeliumScript), for
+// apps under test to launch through App.BrowserHost.
+func scriptHost(t *testing.T, bin string) BrowserHost {
+	t.Helper()
+	origGrace := heliumStartupGrace
+	t.Cleanup(func() { heliumStartupGrace = origGrace })
+	heliumStartupGrace = 100 * time.Millisecond
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	shortRuntimeDir(t)
+	return &HeliumHost{Path: bin}
+}
+
+// shortRuntimeDir points XDG_RUNTIME_DIR at a fresh short path: unix socket
+// paths are capped at ~108 bytes and t.TempDir names run long.
+func shortRuntimeDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "ecrt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	return dir
+}
+
+func TestRun_WindowOwned_ExitsWithWindow(t *testing.T) {
+	script, _ := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.window_owned"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+	)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the window exited")
+	}
+}
+
+func TestRun_Background_OutlivesWindowAndReopens(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.background"),
+		WithContext(ctx),
+		WithBrowserHost(host),
+		WithBackground(true),
+	)
+	taskDone := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+
+	// Wait until the first window has come and gone.
+	deadline := time.Now().Add(3 * time.Second)
+	for countLaunches(t, launches) < 1 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case err := <-errCh:
+		t.Fatalf("Run returned after window exit in background mode: %v", err)
+	default:
+	}
+	if err := app.BackgroundRun(FunctionTask(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(taskDone)
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.OpenWindow(); err != nil {
+		t.Fatalf("OpenWindow: %v", err)
+	}
+	if n := countLaunches(t, launches); n != 2 {
+		t.Fatalf("launches = %d, want 2", n)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not exit after cancel")
+	}
+	select {
+	case <-taskDone:
+	default:
+		t.Fatal("background task did not stop with Run")
+	}
+}
+
+func TestOpenWindow_NotRunning(t *testing.T) {
+	app := New(http.NotFoundHandler())
+	if err := app.OpenWindow(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("want ErrNotRunning, got %v", err)
+	}
+}
+
+func TestBindFlags_Background(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	app := New(http.NotFoundHandler())
+	app.BindFlags(fs)
+	if err := fs.Parse([]string{"-" + BackgroundFlagName}); err != nil {
+		t.Fatal(err)
+	}
+	if !app.Background {
+		t.Fatal("-background did not set App.Background")
+	}
+}
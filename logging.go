@@ -0,0 +1,108 @@
+package eletrocromo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log phases (the "phase" attribute on every record Run emits).
+const (
+	phaseInstance = "instance"
+	phaseResolve  = "resolve"
+	phaseEnsure   = "ensure"
+	phaseServe    = "serve"
+	phaseWindow   = "window"
+	phaseTask     = "task"
+	phaseTray     = "tray"
+	phaseShutdown = "shutdown"
+)
+
+// redactedToken replaces AuthToken wherever it shows up verbatim.
+const redactedToken = "[REDACTED]"
+
+// logger returns App.Logger (slog.Default when nil) behind the redacting
+// handler, with app_id, pid and phase attached.
+func (a *App) logger(phase string) *slog.Logger {
+	base := a.Logger
+	if base == nil {
+		base = slog.Default()
+	}
+	h := &redactHandler{next: base.Handler(), secret: a.AuthToken}
+	return slog.New(h).With("app_id", a.ID, "pid", os.Getpid(), "phase", phase)
+}
+
+// redactHandler scrubs every record before it reaches the real handler:
+// URLs lose their query and fragment, token=… pairs are dropped (see
+// redactSecretsInText) and the live AuthToken is masked anywhere. Strings,
+// errors and Stringers (e.g. *url.URL) are all scrubbed, in groups too.
+type redactHandler struct {
+	next   slog.Handler
+	secret string
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.redact(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		clean[i] = h.redactAttr(attr)
+	}
+	return &redactHandler{next: h.next.WithAttrs(clean), secret: h.secret}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), secret: h.secret}
+}
+
+func (h *redactHandler) redactAttr(attr slog.Attr) slog.Attr {
+	v := attr.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		clean := make([]any, len(group))
+		for i, a := range group {
+			clean[i] = h.redactAttr(a)
+		}
+		return slog.Group(attr.Key, clean...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(attr.Key, h.redact(x.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, h.redact(x.String()))
+		case []string:
+			clean := make([]string, len(x))
+			for i, s := range x {
+				clean[i] = h.redact(s)
+			}
+			return slog.Any(attr.Key, clean)
+		default:
+			return slog.String(attr.Key, h.redact(fmt.Sprint(x)))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: v}
+}
+
+func (h *redactHandler) redact(s string) string {
+	s = redactSecretsInText(s)
+	if h.secret != "" {
+		s = strings.ReplaceAll(s, h.secret, redactedToken)
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package eletrocromo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for the server and test goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRedactHandler_ScrubsEveryValue(t *testing.T) {
+	var buf bytes.Buffer
+	app := &App{
+		ID:        "br.tec.lew.test.redact",
+		AuthToken: "s3cr3t-auth-token",
+		Logger:    slog.New(slog.NewJSONHandler(&buf, nil)),
+	}
+	u, err := url.Parse("http://127.0.0.1:1/?token=bootstrap-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.logger(phaseServe).
+		With("pre", "http://127.0.0.1:1/x?token=bootstrap-secret").
+		WithGroup("g").
+		Info("open http://127.0.0.1:1/?token=bootstrap-secret",
+			"url", u,
+			"err", errors.New("dial: Bearer s3cr3t-auth-token refused"),
+			"args", []string{"--app=http://127.0.0.1:1/?token=bootstrap-secret"},
+			slog.Group("nested", "token", "s3cr3t-auth-token"))
+
+	out := buf.String()
+	for _, secret := range []string{"bootstrap-secret", "s3cr3t-auth-token"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("record leaks %q:\n%s", secret, out)
+		}
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["app_id"] != app.ID || rec["phase"] != phaseServe || rec["pid"] == nil {
+		t.Fatalf("missing standard attrs: %v", rec)
+	}
+	if rec["pre"] != "http://127.0.0.1:1/x" {
+		t.Fatalf("pre = %v", rec["pre"])
+	}
+}
+
+func TestRun_LogsNeverContainTokens(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	var buf syncBuffer
+	ctx, cancel := context.WithCancel(t.Context())
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.logging"),
+		WithContext(ctx),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	link, errCh := runNoUI(t, app)
+	// Make the server log something with the secrets in flight.
+	if code, _ := get(t, sessionClient(t), link); code != http.StatusNotFound {
+		t.Fatalf("bootstrap: %d", code)
+	}
+	token := app.AuthToken
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+
+	bootstrap := link[strings.Index(link, "token=")+len("token="):]
+	out := buf.String()
+	if !strings.Contains(out, "webserver started") || !strings.Contains(out, "phase=serve") {
+		t.Fatalf("expected serve records:\n%s", out)
+	}
+	for _, secret := range []string{token, bootstrap} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log leaks a token:\n%s", out)
+		}
+	}
+}
@@ -1,28 +1,44 @@
 package eletrocromo
 
 import (
-	"bytes"
+	"bufio"
 	"context"
 	"io"
-	"log"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
 )
 
 func TestRun_NoUI_PrintsReadyAndServes(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
 
-	var buf bytes.Buffer
-	prev := log.Writer()
-	log.SetOutput(io.MultiWriter(prev, &buf))
-	defer log.SetOutput(prev)
+	// The READY line goes to stdout only (never to the logger: it carries a
+	// bootstrap token).
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = prev }()
+	ready := make(chan string, 1)
+	go func() {
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			if line, ok := strings.CutPrefix(sc.Text(), ReadyLinePrefix); ok {
+				ready <- strings.TrimSpace(line)
+				return
+			}
+		}
+	}()
 
 	app := &App{
-		ID:    "br.tec.lew.eletrocromo.noui_test",
-		NoUI:  true,
+		ID:      "br.tec.lew.eletrocromo.noui_test",
+		NoUI:    true,
 		Context: ctx,
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if _, err := w.Write([]byte("pong")); err != nil {
@@ -34,26 +50,16 @@ func TestRun_NoUI_PrintsReadyAndServes(t *testing.T) {
 	errCh := make(chan error, 1)
 	go func() { errCh <- app.Run() }()
 
-	// Wait for READY line.
 	var link string
-	deadline := time.Now().Add(3 * time.Second)
-	for time.Now().Before(deadline) {
-		if i := strings.Index(buf.String(), ReadyLinePrefix); i >= 0 {
-			rest := buf.String()[i+len(ReadyLinePrefix):]
-			if j := strings.IndexByte(rest, '\n'); j >= 0 {
-				link = strings.TrimSpace(rest[:j])
-			} else {
-				link = strings.TrimSpace(rest)
-			}
-			if link != "" {
-				break
-			}
-		}
-		time.Sleep(20 * time.Millisecond)
-	}
-	if link == "" {
+	select {
+	case link = <-ready:
+	case <-time.After(3 * time.Second):
 		cancel()
-		t.Fatalf("no READY line in logs:\n%s", buf.String())
+		t.Fatal("no READY line on stdout")
+	}
+	os.Stdout = prev
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
 	}
 	if !strings.HasPrefix(link, "http://127.0.0.1:") && !strings.HasPrefix(link, "http://localhost:") {
 		t.Fatalf("unexpected READY url %q", link)
@@ -62,7 +68,7 @@ func TestRun_NoUI_PrintsReadyAndServes(t *testing.T) {
 		t.Fatalf("READY url missing token: %q", link)
 	}
 
-	resp, err := http.Get(link)
+	resp, err := sessionClient(t).Get(link)
 	if err != nil {
 		cancel()
 		t.Fatal(err)
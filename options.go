@@ -0,0 +1,292 @@
+package eletrocromo
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Option configures an App built by New or NewServer.
+//
+// Options are explicit settings and win over the ELETROCROMO_* env vars, which
+// only act as defaults. Precedence, highest first:
+//
+//   - NoUI: WithNoUI → App.NoUI → ELETROCROMO_NO_UI → window mode.
+//   - Background: WithBackground → App.Background (-background) →
+//     ELETROCROMO_BACKGROUND → window-owned.
+//   - Ensure: WithEnsure → ELETROCROMO_NO_ENSURE → ensure on.
+//   - Workspaced: WithWorkspacedPath (or App.WorkspacedPath) →
+//     ELETROCROMO_WORKSPACED → PATH → pinned bootstrap.
+//   - Auth token: WithAuthToken (or App.AuthToken) → minted per Run.
+type Option func(*App)
+
+// New returns an App that serves handler behind the token gate.
+// Run still requires an App.ID (WithID).
+func New(handler http.Handler, opts ...Option) *App {
+	a := &App{Handler: handler}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// NewServer returns an App that serves srv's handler behind the token gate.
+//
+// The library stays the owner of the loopback bind: srv.Addr must be empty
+// (Run returns ErrServerAddr otherwise). srv.Handler is moved to App.Handler
+// so every request passes ServeHTTP. srv itself is never served: each Run
+// serves a fresh http.Server that takes srv's settings (timeouts,
+// MaxHeaderBytes, ErrorLog, ConnState, a clone of TLSConfig, HTTP/2
+// settings, …), with BaseContext wrapped so request contexts keep its values
+// and are cancelled with the app. srv is not modified, so the App can Run
+// again.
+func NewServer(srv *http.Server, opts ...Option) *App {
+	a := &App{Server: srv}
+	if srv != nil {
+		a.Handler = srv.Handler
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// WithID sets App.ID (reverse-domain application identity).
+func WithID(id string) Option {
+	return func(a *App) {
+		a.ID = id
+	}
+}
+
+// WithContext sets App.Context; cancel it to shut the app down.
+func WithContext(ctx context.Context) Option {
+	return func(a *App) {
+		a.Context = ctx
+	}
+}
+
+// WithNoUI turns NoUI mode (no Helium, loopback HTTP only) on or off for
+// this app, overriding App.NoUI and ELETROCROMO_NO_UI either way.
+func WithNoUI(enabled bool) Option {
+	return func(a *App) {
+		a.noUI = &enabled
+	}
+}
+
+// WithBackground turns background lifetime (keep running after the window
+// closes) on or off, overriding App.Background and ELETROCROMO_BACKGROUND
+// either way.
+func WithBackground(enabled bool) Option {
+	return func(a *App) {
+		a.background = &enabled
+	}
+}
+
+// WithTray shows the system tray (Open/Quit) even in window-owned mode and
+// appends items to its menu.
+func WithTray(items ...TrayItem) Option {
+	return func(a *App) {
+		a.Tray = true
+		a.TrayItems = append(a.TrayItems, items...)
+	}
+}
+
+// WithMultiInstance disables the single-instance lock (see App.MultiInstance).
+func WithMultiInstance() Option {
+	return func(a *App) {
+		a.MultiInstance = true
+	}
+}
+
+// WithOnResume sets the callback that receives a second launch's args in the
+// running instance (see App.OnResume).
+func WithOnResume(fn func(ResumeRequest)) Option {
+	return func(a *App) {
+		a.OnResume = fn
+	}
+}
+
+// WithOnReady calls fn with the server's base URL once it accepts
+// requests (see App.OnReady).
+func WithOnReady(fn func(url string)) Option {
+	return func(a *App) {
+		a.OnReady = fn
+	}
+}
+
+// WithOnWindowOpened calls fn with the Helium pid after each launch (see
+// App.OnWindowOpened).
+func WithOnWindowOpened(fn func(pid int)) Option {
+	return func(a *App) {
+		a.OnWindowOpened = fn
+	}
+}
+
+// WithOnWindowClosed calls fn when the window's process exits (see
+// App.OnWindowClosed).
+func WithOnWindowClosed(fn func(err error)) Option {
+	return func(a *App) {
+		a.OnWindowClosed = fn
+	}
+}
+
+// WithOnShutdown calls fn when shutdown starts and completes (see
+// App.OnShutdown).
+func WithOnShutdown(fn func(ShutdownPhase)) Option {
+	return func(a *App) {
+		a.OnShutdown = fn
+	}
+}
+
+// WithOnTargetEvent calls fn for the window's DevTools target events (see
+// App.OnTargetEvent).
+func WithOnTargetEvent(fn func(TargetEvent)) Option {
+	return func(a *App) {
+		a.OnTargetEvent = fn
+	}
+}
+
+// WithWindow sets the window presentation (see App.Window).
+func WithWindow(o WindowOptions) Option {
+	return func(a *App) {
+		a.Window = o
+	}
+}
+
+// WithLogger sends Run's logs to l (see App.Logger).
+func WithLogger(l *slog.Logger) Option {
+	return func(a *App) {
+		a.Logger = l
+	}
+}
+
+// WithListener serves on ln instead of binding (see App.Listener).
+func WithListener(ln net.Listener) Option {
+	return func(a *App) {
+		a.Listener = ln
+	}
+}
+
+// WithPort binds a fixed loopback port; Run fails if it is taken.
+func WithPort(port int) Option {
+	return func(a *App) {
+		a.Port = port
+	}
+}
+
+// WithPreferredPort tries port first and falls back to an ephemeral one.
+func WithPreferredPort(port int) Option {
+	return func(a *App) {
+		a.PreferredPort = port
+	}
+}
+
+// WithPersistentPort reuses the port from the previous Run of this App.ID
+// (see App.PersistPort).
+func WithPersistentPort() Option {
+	return func(a *App) {
+		a.PersistPort = true
+	}
+}
+
+// WithSecurityHeader overrides one baseline security header (or adds one);
+// an empty value disables it. See App.SecurityHeaders.
+func WithSecurityHeader(name, value string) Option {
+	return func(a *App) {
+		if a.SecurityHeaders == nil {
+			a.SecurityHeaders = make(map[string]string)
+		}
+		a.SecurityHeaders[name] = value
+	}
+}
+
+// WithSessionTTL sets the session cookie lifetime (see App.SessionTTL).
+func WithSessionTTL(d time.Duration) Option {
+	return func(a *App) {
+		a.SessionTTL = d
+	}
+}
+
+// WithAllowedHosts accepts extra Host header values (see App.AllowedHosts).
+func WithAllowedHosts(hosts ...string) Option {
+	return func(a *App) {
+		a.AllowedHosts = append(a.AllowedHosts, hosts...)
+	}
+}
+
+// WithOnUnauthorized renders 401 responses with h (see App.OnUnauthorized).
+func WithOnUnauthorized(h http.Handler) Option {
+	return func(a *App) {
+		a.OnUnauthorized = h
+	}
+}
+
+// WithAuthEvents reports refused requests to fn (see App.OnAuthEvent).
+func WithAuthEvents(fn func(AuthEvent)) Option {
+	return func(a *App) {
+		a.OnAuthEvent = fn
+	}
+}
+
+// WithAuthThrottle sets the invalid-credential limit and lockout (see
+// App.MaxAuthFailures); a negative limit disables throttling.
+func WithAuthThrottle(limit int, lockout time.Duration) Option {
+	return func(a *App) {
+		a.MaxAuthFailures = limit
+		a.AuthLockout = lockout
+	}
+}
+
+// WithShutdownTimeout sets the HTTP drain deadline (see App.ShutdownTimeout).
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(a *App) {
+		a.ShutdownTimeout = d
+	}
+}
+
+// WithTaskTimeout sets the background task wait deadline (see App.TaskTimeout).
+func WithTaskTimeout(d time.Duration) Option {
+	return func(a *App) {
+		a.TaskTimeout = d
+	}
+}
+
+// WithTaskStart sets when Run starts its queued tasks (see App.TaskStart).
+func WithTaskStart(p TaskStartPolicy) Option {
+	return func(a *App) {
+		a.TaskStart = p
+	}
+}
+
+// WithEnsure turns Helium ensure via workspaced on or off for this app,
+// overriding ELETROCROMO_NO_ENSURE either way.
+func WithEnsure(enabled bool) Option {
+	return func(a *App) {
+		a.ensure = &enabled
+	}
+}
+
+// WithWorkspacedPath pins the workspaced binary used for ensure, overriding
+// ELETROCROMO_WORKSPACED.
+func WithWorkspacedPath(path string) Option {
+	return func(a *App) {
+		a.WorkspacedPath = path
+	}
+}
+
+// WithBrowserHost replaces the Helium resolve and launch pipeline (see
+// App.BrowserHost).
+func WithBrowserHost(h BrowserHost) Option {
+	return func(a *App) {
+		a.BrowserHost = h
+	}
+}
+
+// WithAuthToken sets a deliberate session token instead of minting one per Run.
+func WithAuthToken(token string) Option {
+	return func(a *App) {
+		a.AuthToken = token
+	}
+}
@@ -0,0 +1,165 @@
+package eletrocromo
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew_AppliesOptions(t *testing.T) {
+	h := http.NotFoundHandler()
+	ctx := t.Context()
+	app := New(h,
+		WithID("br.tec.lew.eletrocromo.options"),
+		WithContext(ctx),
+		WithNoUI(true),
+		WithAuthToken("fixed-token"),
+		WithWorkspacedPath("/opt/workspaced"),
+		WithEnsure(false),
+	)
+	if app.Handler == nil || app.ID != "br.tec.lew.eletrocromo.options" || app.Context != ctx {
+		t.Fatalf("handler/id/context not applied: %+v", app)
+	}
+	if app.noUI == nil || !*app.noUI || app.AuthToken != "fixed-token" || app.WorkspacedPath != "/opt/workspaced" {
+		t.Fatalf("options not applied: %+v", app)
+	}
+	if app.ensure == nil || *app.ensure {
+		t.Fatal("WithEnsure(false) not applied")
+	}
+}
+
+func TestOptions_EnsurePrecedence(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		env  string
+		want bool
+	}{
+		{name: "default on", want: true},
+		{name: "env disables", env: "1", want: false},
+		{name: "option beats env", opts: []Option{WithEnsure(true)}, env: "1", want: true},
+		{name: "option disables", opts: []Option{WithEnsure(false)}, want: false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ELETROCROMO_NO_ENSURE", tt.env)
+			app := New(nil, tt.opts...)
+			if got := app.browserHost().(*HeliumHost).ensureEnabled(); got != tt.want {
+				t.Fatalf("ensureEnabled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_NoUIPrecedence(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  []Option
+		field bool
+		env   string
+		want  bool
+	}{
+		{name: "default window", want: false},
+		{name: "env enables", env: "1", want: true},
+		{name: "field enables", field: true, want: true},
+		{name: "option beats env", opts: []Option{WithNoUI(false)}, env: "1", want: false},
+		{name: "option beats field", opts: []Option{WithNoUI(false)}, field: true, want: false},
+		{name: "option enables", opts: []Option{WithNoUI(true)}, want: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ELETROCROMO_NO_UI", tt.env)
+			app := New(nil, tt.opts...)
+			app.NoUI = tt.field
+			if got := app.noUIMode(); got != tt.want {
+				t.Fatalf("noUIMode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_BackgroundPrecedence(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		flag bool
+		env  string
+		want bool
+	}{
+		{name: "default window-owned", want: false},
+		{name: "env enables", env: "1", want: true},
+		{name: "flag enables", flag: true, want: true},
+		{name: "option beats env", opts: []Option{WithBackground(false)}, env: "1", want: false},
+		{name: "option beats flag", opts: []Option{WithBackground(false)}, flag: true, want: false},
+		{name: "option enables", opts: []Option{WithBackground(true)}, want: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ELETROCROMO_BACKGROUND", tt.env)
+			app := New(nil, tt.opts...)
+			fs := flag.NewFlagSet("app", flag.ContinueOnError)
+			app.BindFlags(fs)
+			var args []string
+			if tt.flag {
+				args = append(args, "-"+BackgroundFlagName)
+			}
+			if err := fs.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+			if got := app.backgroundMode(); got != tt.want {
+				t.Fatalf("backgroundMode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_WorkspacedPrecedence(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		env  string
+		want string
+	}{
+		{name: "unset"},
+		{name: "env", env: "/env/workspaced", want: "/env/workspaced"},
+		{name: "option beats env", opts: []Option{WithWorkspacedPath("/opt/workspaced")}, env: "/env/workspaced", want: "/opt/workspaced"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ELETROCROMO_WORKSPACED", tt.env)
+			app := New(nil, tt.opts...)
+			if got := app.browserHost().(*HeliumHost).workspacedPath(); got != tt.want {
+				t.Fatalf("workspacedPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// WithEnsure(false) must keep Run off the network even when env allows ensure.
+func TestRun_WithEnsureFalse_NoWorkspaced(t *testing.T) {
+	dir := t.TempDir()
+	ran := filepath.Join(dir, "ran")
+	workspaced := filepath.Join(dir, "workspaced")
+	if err := os.WriteFile(workspaced, []byte("#!/bin/sh\ntouch "+ran+"\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", t.TempDir()) // no local Helium
+	t.Setenv("ELETROCROMO_WORKSPACED", workspaced)
+	t.Setenv("ELETROCROMO_NO_ENSURE", "")
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.eletrocromo.noensure"),
+		WithContext(t.Context()),
+		WithEnsure(false),
+	)
+	if err := app.Run(); !errors.Is(err, ErrNoChromium) {
+		t.Fatalf("want ErrNoChromium, got %v", err)
+	}
+	if _, err := os.Stat(ran); err == nil {
+		t.Fatal("workspaced must not run with WithEnsure(false)")
+	}
+}
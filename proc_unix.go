@@ -9,6 +9,10 @@ import (
 	"time"
 )
 
+// devToolsPipeSupported: exec.Cmd.ExtraFiles carries the DevTools pipe
+// fds to Helium.
+const devToolsPipeSupported = true
+
 func isESRCH(err error) bool {
 	return errors.Is(err, syscall.ESRCH)
 }
@@ -4,6 +4,10 @@ package eletrocromo
 
 import "os/exec"
 
+// devToolsPipeSupported is false: exec.Cmd.ExtraFiles is not supported on
+// Windows, so windows have no DevTools client.
+const devToolsPipeSupported = false
+
 func putInOwnProcessGroup(cmd *exec.Cmd) {
 	// Windows job objects are the proper analogue; out of scope for Linux-first.
 }
@@ -0,0 +1,290 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCronSpec is returned by NewCronTask for an unparsable expression.
+	ErrCronSpec = errors.New("invalid cron expression")
+	// ErrScheduleInterval is returned by NewPeriodicTask for an interval <= 0.
+	ErrScheduleInterval = errors.New("schedule interval must be positive")
+)
+
+// ScheduleOption tunes NewPeriodicTask and NewCronTask.
+type ScheduleOption func(*scheduleConfig)
+
+type scheduleConfig struct {
+	runOnStart bool
+	jitter     time.Duration
+}
+
+// RunOnStart also runs fn as soon as the task starts, before the first
+// scheduled time.
+func RunOnStart() ScheduleOption {
+	return func(c *scheduleConfig) {
+		c.runOnStart = true
+	}
+}
+
+// StartJitter delays the task's start (and RunOnStart) by a random duration
+// in [0, d), so apps launched together do not hit a backend in lockstep.
+func StartJitter(d time.Duration) ScheduleOption {
+	return func(c *scheduleConfig) {
+		c.jitter = d
+	}
+}
+
+// schedule yields the next run time strictly after now. anchor is when this
+// Run of the task started; the schedule itself holds no per-Run state, so
+// one Task can be registered more than once.
+type schedule interface {
+	next(anchor, now time.Time) time.Time
+}
+
+// NewPeriodicTask runs fn every interval until cancel. Runs never overlap:
+// ticks that fall while fn is still running are skipped, and the next run
+// stays on the interval grid. fn errors and panics are logged; the schedule
+// goes on (wrap the result with Supervise/Critical for other policies), and
+// the last one shows in the task's TaskInfo.LastError.
+func NewPeriodicTask(interval time.Duration, fn func(context.Context) error, opts ...ScheduleOption) (Task, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: %v", ErrScheduleInterval, interval)
+	}
+	return newScheduledTask(every{interval: interval}, fn, opts), nil
+}
+
+// NewCronTask runs fn on a cron schedule in local time, with the same
+// no-overlap and error handling as NewPeriodicTask. spec is the classic
+// five fields (minute hour day-of-month month day-of-week; *, lists, ranges,
+// /steps, JAN–DEC and SUN–SAT names) or one of @yearly, @monthly, @weekly,
+// @daily, @hourly and "@every <duration>". Day-of-month and day-of-week match
+// either when both are restricted, as in Vixie cron.
+func NewCronTask(spec string, fn func(context.Context) error, opts ...ScheduleOption) (Task, error) {
+	sched, err := parseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return newScheduledTask(sched, fn, opts), nil
+}
+
+type scheduledTask struct {
+	sched schedule
+	fn    func(context.Context) error
+	cfg   scheduleConfig
+}
+
+func newScheduledTask(sched schedule, fn func(context.Context) error, opts []ScheduleOption) *scheduledTask {
+	t := &scheduledTask{sched: sched, fn: fn}
+	for _, opt := range opts {
+		opt(&t.cfg)
+	}
+	return t
+}
+
+func (t *scheduledTask) Run(ctx context.Context) error {
+	if t.cfg.jitter > 0 {
+		if !sleepCtx(ctx, rand.N(t.cfg.jitter)) {
+			return nil
+		}
+	}
+	anchor := time.Now()
+	if t.cfg.runOnStart {
+		t.fire(ctx)
+	}
+	for {
+		now := time.Now()
+		if !sleepCtx(ctx, t.sched.next(anchor, now).Sub(now)) {
+			return nil
+		}
+		t.fire(ctx)
+	}
+}
+
+func (t *scheduledTask) fire(ctx context.Context) {
+	err := runTask(ctx, FunctionTask(t.fn))
+	if err != nil && ctx.Err() == nil {
+		taskLogger(ctx).Warn("scheduled run failed", "err", err)
+		taskUpdate(ctx, func(info *TaskInfo) { info.LastError = err.Error() })
+	}
+}
+
+// sleepCtx waits d; false means ctx was cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// every fires on the grid anchor + k·interval.
+type every struct {
+	interval time.Duration
+}
+
+func (e every) next(anchor, now time.Time) time.Time {
+	k := now.Sub(anchor)/e.interval + 1
+	return anchor.Add(k * e.interval)
+}
+
+// cronSchedule holds one bit per allowed value of each field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dowNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+func parseCron(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: %q: bad duration", ErrCronSpec, spec)
+		}
+		return every{interval: d}, nil
+	}
+	if expanded, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q: want 5 fields, got %d", ErrCronSpec, spec, len(fields))
+	}
+	var s cronSchedule
+	var err error
+	parse := func(i, lo, hi int, names []string, nameBase int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var bits uint64
+		bits, err = parseCronField(fields[i], lo, hi, names, nameBase)
+		if err != nil {
+			err = fmt.Errorf("%w: %q: field %d: %w", ErrCronSpec, spec, i+1, err)
+		}
+		return bits
+	}
+	s.minute = parse(0, 0, 59, nil, 0)
+	s.hour = parse(1, 0, 23, nil, 0)
+	s.dom = parse(2, 1, 31, nil, 0)
+	s.month = parse(3, 1, 12, monthNames, 1)
+	s.dow = parse(4, 0, 7, dowNames, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 7 is Sunday too.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return &s, nil
+}
+
+func parseCronField(field string, lo, hi int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for item := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step %q", stepStr)
+			}
+			step = n
+		}
+		start, end := lo, hi
+		switch {
+		case rng == "*":
+		default:
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = cronValue(a, lo, hi, names, nameBase); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if end, err = cronValue(b, lo, hi, names, nameBase); err != nil {
+					return 0, err
+				}
+				if end < start {
+					return 0, fmt.Errorf("bad range %q", rng)
+				}
+			case !hasStep:
+				end = start
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, lo, hi int, names []string, nameBase int) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, lo, hi)
+	}
+	return v, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s *cronSchedule) next(_, now time.Time) time.Time {
+	loc := now.Location()
+	t := now.Truncate(time.Minute).Add(time.Minute)
+	// Five years covers every satisfiable expression (Feb 29 included).
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	// Unsatisfiable (e.g. 30 February): never fire.
+	return now.AddDate(100, 0, 0)
+}
@@ -0,0 +1,209 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewPeriodicTask_RunsAndStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	var runs atomic.Int32
+	task, err := NewPeriodicTask(10*time.Millisecond, func(context.Context) error {
+		if runs.Add(1) == 3 {
+			cancel()
+		}
+		return ErrTestBoom // logged; the schedule goes on
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- task.Run(ctx) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("periodic task did not stop")
+	}
+	if runs.Load() != 3 {
+		t.Fatalf("runs = %d", runs.Load())
+	}
+}
+
+func TestNewPeriodicTask_SkipsOverlapAndRunsOnStart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 95*time.Millisecond)
+	defer cancel()
+	var runs, running atomic.Int32
+	var overlapped atomic.Bool
+	start := time.Now()
+	var first atomic.Int64
+	task, err := NewPeriodicTask(10*time.Millisecond, func(context.Context) error {
+		first.CompareAndSwap(0, int64(time.Since(start)))
+		if running.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		defer running.Add(-1)
+		runs.Add(1)
+		time.Sleep(35 * time.Millisecond) // spans several ticks
+		return nil
+	}, RunOnStart())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if overlapped.Load() {
+		t.Fatal("runs overlapped")
+	}
+	if time.Duration(first.Load()) > 5*time.Millisecond {
+		t.Fatalf("RunOnStart ran after %v", time.Duration(first.Load()))
+	}
+	// ~95ms / (35ms run + wait to the next 10ms tick) ⇒ 2–3 runs, not 9.
+	if n := runs.Load(); n < 2 || n > 3 {
+		t.Fatalf("runs = %d", n)
+	}
+}
+
+func TestNewPeriodicTask_StartJitterHonoursCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	task, err := NewPeriodicTask(time.Hour, func(context.Context) error {
+		t.Error("ran after cancel")
+		return nil
+	}, RunOnStart(), StartJitter(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPeriodicTask_RejectsBadInterval(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, err := NewPeriodicTask(d, func(context.Context) error { return nil }); !errors.Is(err, ErrScheduleInterval) {
+			t.Errorf("%v: err = %v", d, err)
+		}
+	}
+}
+
+func TestNewPeriodicTask_RegisteredTwice(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 60*time.Millisecond)
+	defer cancel()
+	var runs atomic.Int32
+	task, err := NewPeriodicTask(10*time.Millisecond, func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each Run keeps its own grid; run with -race to catch shared state.
+	done := make(chan error, 2)
+	for range 2 {
+		go func() { done <- task.Run(ctx) }()
+	}
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := runs.Load(); n < 6 {
+		t.Fatalf("runs = %d, want both copies on schedule", n)
+	}
+}
+
+func TestNewPeriodicTask_ErrorsReachTaskInfo(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := afterRun(&App{Context: ctx})
+	task, err := NewPeriodicTask(10*time.Millisecond, func(context.Context) error {
+		return ErrTestBoom
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BackgroundRun(NamedTask("sync", task)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := app.Tasks(); len(got) == 1 && got[0].LastError == ErrTestBoom.Error() {
+			if got[0].State != TaskRunning {
+				t.Fatalf("sync = %+v, want still running", got[0])
+			}
+			cancel()
+			app.WaitGroup.Wait()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("LastError never set: %+v", app.Tasks())
+}
+
+func TestParseCron_Next(t *testing.T) {
+	loc := time.UTC
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec, now, want string
+	}{
+		{"* * * * *", "2026-03-10 12:00", "2026-03-10 12:01"},
+		{"*/15 * * * *", "2026-03-10 12:07", "2026-03-10 12:15"},
+		{"30 9 * * MON-FRI", "2026-03-13 10:00", "2026-03-16 09:30"}, // Fri → Mon
+		{"0 0 1 JAN *", "2026-03-10 12:00", "2027-01-01 00:00"},
+		{"@hourly", "2026-03-10 12:30", "2026-03-10 13:00"},
+		{"@weekly", "2026-03-10 12:30", "2026-03-15 00:00"},
+		{"0 12 13 * 5", "2026-03-10 00:00", "2026-03-13 12:00"}, // Friday the 13th: dom or dow
+		{"0 12 1 * 7", "2026-03-10 00:00", "2026-03-15 12:00"},  // 7 is Sunday
+		{"0 0 29 2 *", "2026-03-10 00:00", "2028-02-29 00:00"},
+		{"5,10-12 3 * * *", "2026-03-10 03:10", "2026-03-10 03:11"},
+		{"10-20/5 * * * *", "2026-03-10 03:16", "2026-03-10 03:20"},
+	}
+	for _, tt := range tests {
+		sched, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := sched.next(time.Time{}, at(tt.now)); !got.Equal(at(tt.want)) {
+			t.Errorf("%s after %s = %s, want %s", tt.spec, tt.now, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestNewCronTask_RejectsBadSpecs(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * FOO *", "@every nope"} {
+		if _, err := NewCronTask(spec, func(context.Context) error { return nil }); !errors.Is(err, ErrCronSpec) {
+			t.Errorf("%q: err = %v", spec, err)
+		}
+	}
+}
+
+func TestNewCronTask_Every(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	var runs atomic.Int32
+	task, err := NewCronTask("@every 10ms", func(context.Context) error {
+		if runs.Add(1) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,274 @@
+package eletrocromo
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitReadyFile polls ELETROCROMO_READY_FILE until Run writes the link.
+func waitReadyFile(t *testing.T, path string) string {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if b, err := os.ReadFile(path); err == nil {
+			if link := strings.TrimSpace(string(b)); link != "" {
+				return link
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no ready link written to %s", path)
+	return ""
+}
+
+func TestNewServer_HonoursServerConfig(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	t.Setenv("ELETROCROMO_READY_FILE", readyFile)
+
+	var conns atomic.Int32
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := io.WriteString(w, "pong"); err != nil {
+				return
+			}
+		}),
+		MaxHeaderBytes: 1 << 10,
+		ConnState: func(_ net.Conn, st http.ConnState) {
+			if st == http.StateNew {
+				conns.Add(1)
+			}
+		},
+	}
+	app := NewServer(srv,
+		WithID("br.tec.lew.eletrocromo.server_test"),
+		WithContext(ctx),
+	)
+	app.NoUI = true
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	link := waitReadyFile(t, readyFile)
+
+	client := sessionClient(t)
+	resp, err := client.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "pong" {
+		t.Fatalf("status=%d body=%q", resp.StatusCode, body)
+	}
+	if conns.Load() == 0 {
+		t.Fatal("caller ConnState hook was not used")
+	}
+
+	// MaxHeaderBytes from the caller's server must apply.
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Big", strings.Repeat("a", 64<<10))
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("want 431 from MaxHeaderBytes, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run did not exit after cancel")
+	}
+}
+
+func TestNewServer_GatesHandler(t *testing.T) {
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	app := NewServer(srv)
+	if app.Handler == nil {
+		t.Fatal("srv.Handler was not adopted as App.Handler")
+	}
+	if app.Server != srv {
+		t.Fatal("App.Server is not the caller's server")
+	}
+}
+
+func TestRun_RejectsServerAddr(t *testing.T) {
+	app := NewServer(&http.Server{Addr: "0.0.0.0:8080"},
+		WithID("br.tec.lew.eletrocromo.addr_test"),
+		WithContext(t.Context()),
+	)
+	app.NoUI = true
+	err := app.Run()
+	if !errors.Is(err, ErrServerAddr) {
+		t.Fatalf("want ErrServerAddr, got %v", err)
+	}
+}
+
+type baseKey struct{}
+
+func TestNewServer_LeavesCallerServerAlone(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	t.Setenv("ELETROCROMO_READY_FILE", readyFile)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, _ := r.Context().Value(baseKey{}).(string)
+		if _, err := io.WriteString(w, v); err != nil {
+			return
+		}
+	})
+	baseContext := func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), baseKey{}, "caller")
+	}
+	srv := &http.Server{Handler: handler, BaseContext: baseContext}
+	app := NewServer(srv,
+		WithID("br.tec.lew.eletrocromo.server_restore"),
+		WithContext(ctx),
+	)
+	app.NoUI = true
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	link := waitReadyFile(t, readyFile)
+
+	resp, err := sessionClient(t).Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "caller" {
+		t.Fatalf("request context lost the caller's BaseContext values: body %q", body)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run did not exit after cancel")
+	}
+	if _, ok := srv.Handler.(http.HandlerFunc); !ok {
+		t.Fatalf("srv.Handler = %T after Run, want the caller's handler", srv.Handler)
+	}
+	if srv.BaseContext == nil || srv.BaseContext(nil).Value(baseKey{}) != "caller" {
+		t.Fatal("srv.BaseContext not restored after Run")
+	}
+}
+
+func TestNewServer_RunsTwice(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.WriteString(w, "pong"); err != nil {
+			return
+		}
+	})}
+	var hooks atomic.Int32
+	app := NewServer(srv, WithID("br.tec.lew.eletrocromo.server_twice"))
+	app.NoUI = true
+	app.RegisterOnShutdown(func() { hooks.Add(1) })
+
+	for run := range 2 {
+		ctx, cancel := context.WithCancel(t.Context())
+		app.Context = ctx
+		readyFile := filepath.Join(t.TempDir(), "ready")
+		t.Setenv("ELETROCROMO_READY_FILE", readyFile)
+		errCh := make(chan error, 1)
+		go func() { errCh <- app.Run() }()
+		link := waitReadyFile(t, readyFile)
+
+		resp, err := sessionClient(t).Get(link)
+		if err != nil {
+			cancel()
+			t.Fatalf("run %d: %v", run, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if cerr := resp.Body.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil || string(body) != "pong" {
+			cancel()
+			t.Fatalf("run %d: body %q, err %v", run, body, err)
+		}
+
+		cancel()
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Fatalf("run %d: %v", run, err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("run %d: Run did not exit after cancel", run)
+		}
+	}
+	if srv.TLSConfig != nil {
+		t.Fatal("Run filled in the caller's TLSConfig")
+	}
+	// Hooks run in their own goroutine; give the last one a moment.
+	deadline := time.Now().Add(time.Second)
+	for hooks.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := hooks.Load(); got != 2 {
+		t.Fatalf("shutdown hook ran %d times over two Runs, want 2", got)
+	}
+}
+
+func TestRun_ServeFailureIsError(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	// No certificate in TLSConfig: ServeTLS fails at once looking for cert
+	// files.
+	app := NewServer(&http.Server{TLSConfig: &tls.Config{}},
+		WithID("br.tec.lew.eletrocromo.server_fail"),
+		WithContext(t.Context()),
+	)
+	app.NoUI = true
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("want the ServeTLS error, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run did not return after the server failed")
+	}
+}
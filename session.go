@@ -0,0 +1,374 @@
+package eletrocromo
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL is the session cookie lifetime when App.SessionTTL is zero.
+const DefaultSessionTTL = 7 * 24 * time.Hour
+
+// ErrNoSessions is returned by the session APIs outside Run (no per-ID key
+// loaded yet).
+var ErrNoSessions = errors.New("sessions are not active (App.Run not started)")
+
+const (
+	sessionKeyFile     = "session.key"
+	sessionRevokedFile = "sessions.revoked"
+	sessionVersion     = "v1"
+)
+
+// sessionStore signs and checks session cookies for one App.ID. Keys live in
+// the per-ID state dir so sessions outlive the process (and its per-run
+// AuthToken); the previous key is kept after a rotation so existing cookies
+// stay valid until they expire or the key is rotated again.
+type sessionStore struct {
+	dir string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	keys    [][]byte             // keys[0] signs; all verify
+	revoked map[string]time.Time // session id → cookie expiry (pruned after)
+}
+
+// openSessionStore loads (or creates) the signing key and revocation list.
+func openSessionStore(appID string, ttl time.Duration) (*sessionStore, error) {
+	dir, err := stateDir(appID)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	s := &sessionStore{dir: dir, ttl: ttl, revoked: make(map[string]time.Time)}
+	keys, err := s.readKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		if err := s.rotate(false); err != nil {
+			return nil, err
+		}
+	} else {
+		s.keys = keys
+	}
+	if err := s.readRevoked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sessionStore) readKeys() ([][]byte, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, sessionKeyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session key: %w", err)
+	}
+	var keys [][]byte
+	for _, line := range strings.Fields(string(b)) {
+		k, err := base64.RawStdEncoding.DecodeString(line)
+		if err != nil || len(k) < 32 {
+			return nil, fmt.Errorf("session key: corrupt %s", sessionKeyFile)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// rotate makes a new signing key. With keepPrevious the old signing key
+// still verifies (graceful); without, every existing session is invalid.
+// Caller holds mu (or owns s exclusively).
+func (s *sessionStore) rotate(keepPrevious bool) error {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		return err
+	}
+	keys := [][]byte{k}
+	if keepPrevious && len(s.keys) > 0 {
+		keys = append(keys, s.keys[0])
+	}
+	var buf strings.Builder
+	for _, key := range keys {
+		buf.WriteString(base64.RawStdEncoding.EncodeToString(key))
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, sessionKeyFile), []byte(buf.String())); err != nil {
+		return fmt.Errorf("session key: %w", err)
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *sessionStore) readRevoked() error {
+	f, err := os.Open(filepath.Join(s.dir, sessionRevokedFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("session revocations: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	now := time.Now()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		id, exp, ok := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		if !ok {
+			continue
+		}
+		unix, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil || now.After(time.Unix(unix, 0)) {
+			continue
+		}
+		s.revoked[id] = time.Unix(unix, 0)
+	}
+	return sc.Err()
+}
+
+// writeRevoked persists the revocation list, dropping entries whose cookie
+// has expired anyway. Caller holds mu.
+func (s *sessionStore) writeRevoked() error {
+	now := time.Now()
+	var buf strings.Builder
+	for id, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, id)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s %d\n", id, exp.Unix())
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, sessionRevokedFile), []byte(buf.String())); err != nil {
+		return fmt.Errorf("session revocations: %w", err)
+	}
+	return nil
+}
+
+// issue returns a signed cookie value for a new session and its id.
+func (s *sessionStore) issue(now time.Time) (value, id string, err error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", err
+	}
+	id = base64.RawURLEncoding.EncodeToString(b[:])
+	payload := strings.Join([]string{
+		sessionVersion, id,
+		strconv.FormatInt(now.Unix(), 10),
+		strconv.FormatInt(now.Add(s.ttl).Unix(), 10),
+	}, "|")
+	s.mu.Lock()
+	key := s.keys[0]
+	s.mu.Unlock()
+	return encodeSession(payload, key), id, nil
+}
+
+func encodeSession(payload string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// session is a verified cookie.
+type session struct {
+	id       string
+	issued   time.Time
+	expires  time.Time
+	staleKey bool // signed with a rotated-out key; reissue
+}
+
+// verify checks signature (any live key), expiry and revocation.
+func (s *sessionStore) verify(value string, now time.Time) (session, bool) {
+	p64, sig64, ok := strings.Cut(value, ".")
+	if !ok {
+		return session{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p64)
+	if err != nil {
+		return session{}, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sig64)
+	if err != nil {
+		return session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	match := -1
+	for i, key := range s.keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(payload)
+		if hmac.Equal(sig, mac.Sum(nil)) {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return session{}, false
+	}
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 4 || parts[0] != sessionVersion {
+		return session{}, false
+	}
+	issued, err1 := strconv.ParseInt(parts[2], 10, 64)
+	expires, err2 := strconv.ParseInt(parts[3], 10, 64)
+	if err1 != nil || err2 != nil || !now.Before(time.Unix(expires, 0)) {
+		return session{}, false
+	}
+	if _, gone := s.revoked[parts[1]]; gone {
+		return session{}, false
+	}
+	return session{
+		id:       parts[1],
+		issued:   time.Unix(issued, 0),
+		expires:  time.Unix(expires, 0),
+		staleKey: match > 0,
+	}, true
+}
+
+// revoke refuses session id from now on (persisted until it would expire).
+func (s *sessionStore) revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[id] = time.Now().Add(s.ttl)
+	return s.writeRevoked()
+}
+
+func (s *sessionStore) rotateKey(keepPrevious bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotate(keepPrevious)
+}
+
+// sessionCookieName namespaces the cookie per App.ID: cookies are scoped by
+// host, not port, so apps sharing 127.0.0.1 would otherwise overwrite each
+// other's session.
+func sessionCookieName(appID string) string {
+	return AUTH_COOKIE_KEY + "." + appID
+}
+
+func (a *App) sessionStore() *sessionStore {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sessions
+}
+
+func (a *App) setSessionStore(s *sessionStore) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions = s
+}
+
+// startSession sets a fresh signed session cookie and returns its id, or
+// sets the legacy raw AuthToken cookie when no session store is loaded
+// (ServeHTTP without Run) and returns "".
+func (a *App) startSession(w http.ResponseWriter) string {
+	s := a.sessionStore()
+	if s == nil {
+		a.setAuthCookie(w)
+		return ""
+	}
+	now := time.Now()
+	value, id, err := s.issue(now)
+	if err != nil {
+		// Without a session cookie the window falls back to 401 on its next
+		// request; nothing better to do mid-response.
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName(a.ID),
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(s.ttl / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return id
+}
+
+// sessionFromCookie authenticates r's session cookie. Sessions past half
+// their lifetime, or signed with a rotated-out key, are renewed in place.
+func (a *App) sessionFromCookie(w http.ResponseWriter, r *http.Request) (AuthInfo, bool) {
+	s := a.sessionStore()
+	if s == nil {
+		return AuthInfo{}, false
+	}
+	c, err := r.Cookie(sessionCookieName(a.ID))
+	if err != nil {
+		return AuthInfo{}, false
+	}
+	now := time.Now()
+	sess, ok := s.verify(c.Value, now)
+	if !ok {
+		return AuthInfo{}, false
+	}
+	if sess.staleKey || now.Sub(sess.issued) > sess.expires.Sub(sess.issued)/2 {
+		a.startSession(w)
+		// The renewed cookie has a new id; the old one stays valid until it
+		// expires so concurrent requests with it are not refused.
+	}
+	return AuthInfo{Name: SessionTokenName, Session: sess.id}, true
+}
+
+// RevokeSession invalidates one browser session (AuthInfo.Session) at once;
+// the revocation survives restarts. Returns ErrNoSessions outside Run.
+func (a *App) RevokeSession(id string) error {
+	s := a.sessionStore()
+	if s == nil {
+		return ErrNoSessions
+	}
+	return s.revoke(id)
+}
+
+// RotateSessionKey replaces the per-ID signing key. New sessions use the new
+// key; existing ones keep working (and are renewed) until the next rotation.
+// Returns ErrNoSessions outside Run.
+func (a *App) RotateSessionKey() error {
+	s := a.sessionStore()
+	if s == nil {
+		return ErrNoSessions
+	}
+	return s.rotateKey(true)
+}
+
+// RevokeAllSessions replaces the signing key without a grace period: every
+// browser session must bootstrap again. Returns ErrNoSessions outside Run.
+func (a *App) RevokeAllSessions() error {
+	s := a.sessionStore()
+	if s == nil {
+		return ErrNoSessions
+	}
+	return s.rotateKey(false)
+}
+
+// writeFileAtomic replaces path via a temp file + rename (0600).
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer removeBestEffort(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
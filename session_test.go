@@ -0,0 +1,158 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionApp runs a NoUI app whose handler reports the caller's session id.
+func sessionApp(t *testing.T, id string) (app *App, link string, stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	app = New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ := AuthFromContext(r.Context())
+		if _, err := io.WriteString(w, info.Session); err != nil {
+			return
+		}
+	}), WithID(id), WithContext(ctx))
+	link, errCh := runNoUI(t, app)
+	return app, link, func() {
+		cancel()
+		if err := waitRun(t, errCh); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func get(t *testing.T, c *http.Client, rawURL string) (int, string) {
+	t.Helper()
+	resp, err := c.Get(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func origin(t *testing.T, link string) string {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+func TestSession_SurvivesRestartAndRevocation(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	const id = "br.tec.lew.test.session"
+	client := sessionClient(t)
+
+	_, link, stop := sessionApp(t, id)
+	code, sid := get(t, client, link)
+	if code != http.StatusOK || sid == "" {
+		t.Fatalf("bootstrap: status=%d session=%q", code, sid)
+	}
+	stop()
+
+	// New process, new AuthToken: the signed cookie still authenticates.
+	app, link, stop := sessionApp(t, id)
+	defer stop()
+	code, again := get(t, client, origin(t, link))
+	if code != http.StatusOK || again != sid {
+		t.Fatalf("after restart: status=%d session=%q want %q", code, again, sid)
+	}
+
+	if err := app.RevokeSession(sid); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := get(t, client, origin(t, link)); code != http.StatusUnauthorized {
+		t.Fatalf("revoked session: want 401, got %d", code)
+	}
+}
+
+func TestSession_CookiesNamespacedPerAppID(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	client := sessionClient(t)
+	_, linkA, stopA := sessionApp(t, "br.tec.lew.test.session_a")
+	defer stopA()
+	_, linkB, stopB := sessionApp(t, "br.tec.lew.test.session_b")
+	defer stopB()
+
+	// Same browser, same 127.0.0.1 cookie scope: bootstrapping B must not
+	// log A out.
+	if code, _ := get(t, client, linkA); code != http.StatusOK {
+		t.Fatalf("A bootstrap: %d", code)
+	}
+	if code, _ := get(t, client, linkB); code != http.StatusOK {
+		t.Fatalf("B bootstrap: %d", code)
+	}
+	if code, _ := get(t, client, origin(t, linkA)); code != http.StatusOK {
+		t.Fatalf("A after B: want 200, got %d", code)
+	}
+}
+
+func TestSessionStore_ExpiryTamperAndRotation(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	s, err := openSessionStore("br.tec.lew.test.session_store", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	value, id, err := s.issue(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess, ok := s.verify(value, now); !ok || sess.id != id || sess.staleKey {
+		t.Fatalf("fresh session: %+v ok=%v", sess, ok)
+	}
+	if _, ok := s.verify(value, now.Add(time.Hour+time.Second)); ok {
+		t.Fatal("expired session accepted")
+	}
+	payload, sig, _ := strings.Cut(value, ".")
+	if _, ok := s.verify(payload+"x."+sig, now); ok {
+		t.Fatal("tampered payload accepted")
+	}
+
+	if err := s.rotateKey(true); err != nil {
+		t.Fatal(err)
+	}
+	sess, ok := s.verify(value, now)
+	if !ok || !sess.staleKey {
+		t.Fatalf("after graceful rotation: ok=%v stale=%v", ok, sess.staleKey)
+	}
+	// The key file is what a restart loads.
+	reopened, err := openSessionStore("br.tec.lew.test.session_store", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.verify(value, now); !ok {
+		t.Fatal("previous key not persisted across reopen")
+	}
+
+	if err := s.rotateKey(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.verify(value, now); ok {
+		t.Fatal("session survived RevokeAllSessions-style rotation")
+	}
+}
+
+func TestSession_APIsOutsideRun(t *testing.T) {
+	app := &App{}
+	for _, err := range []error{app.RevokeSession("x"), app.RotateSessionKey(), app.RevokeAllSessions()} {
+		if !errors.Is(err, ErrNoSessions) {
+			t.Fatalf("want ErrNoSessions, got %v", err)
+		}
+	}
+}
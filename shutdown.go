@@ -0,0 +1,117 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Defaults for App.ShutdownTimeout and App.TaskTimeout.
+const (
+	DefaultShutdownTimeout = 5 * time.Second
+	DefaultTaskTimeout     = 10 * time.Second
+)
+
+// ErrTasksDidNotStop is returned by Run when background tasks are still
+// running TaskTimeout after cancel; the message names them.
+var ErrTasksDidNotStop = errors.New("background tasks did not stop")
+
+// NamedTask labels task for shutdown errors and logs. Tasks can also name
+// themselves by implementing Name() string.
+func NamedTask(name string, task Task) Task {
+	return namedTask{name: name, Task: task}
+}
+
+type namedTask struct {
+	name string
+	Task
+}
+
+func (t namedTask) Name() string { return t.name }
+
+func (t namedTask) Unwrap() Task { return t.Task }
+
+// taskName is the label BackgroundRun tracks a task under.
+func taskName(task Task) string {
+	if n, ok := task.(interface{ Name() string }); ok {
+		if name := strings.TrimSpace(n.Name()); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("%T", task)
+}
+
+// RegisterOnShutdown registers f to run when Run starts draining the server
+// (like http.Server.RegisterOnShutdown): long-lived handlers such as SSE or
+// WebSocket use it to send a goodbye and return. f runs in its own goroutine.
+func (a *App) RegisterOnShutdown(f func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.shutdownHooks = append(a.shutdownHooks, f)
+}
+
+// shutdownServer drains srv for ShutdownTimeout: no new connections, idle
+// ones close, in-flight requests finish. Past the deadline the remaining
+// requests' contexts are cancelled and their connections closed.
+func (a *App) shutdownServer(srv *http.Server, cancelRequests context.CancelFunc) {
+	defer cancelRequests()
+	a.mu.Lock()
+	hooks := slices.Clone(a.shutdownHooks)
+	a.mu.Unlock()
+	for _, f := range hooks {
+		srv.RegisterOnShutdown(f)
+	}
+
+	timeout := a.ShutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx := background
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(background, timeout)
+		defer cancel()
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		a.logger(phaseShutdown).Warn("server drain incomplete; closing remaining connections", "err", err)
+		cancelRequests()
+		if err := srv.Close(); err != nil {
+			a.logger(phaseShutdown).Warn("server close failed", "err", err)
+		}
+	}
+}
+
+// waitTasks waits for WaitGroup up to TaskTimeout and names the stragglers.
+func (a *App) waitTasks() error {
+	done := make(chan struct{})
+	go func() {
+		a.WaitGroup.Wait()
+		close(done)
+	}()
+	timeout := a.TaskTimeout
+	if timeout == 0 {
+		timeout = DefaultTaskTimeout
+	}
+	if timeout < 0 {
+		<-done
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	}
+
+	names := a.runningTaskNames()
+	if len(names) == 0 {
+		// Work added to WaitGroup directly has no name to report.
+		names = append(names, "untracked WaitGroup work")
+	}
+	return fmt.Errorf("%w after %s: %s", ErrTasksDidNotStop, timeout, strings.Join(names, ", "))
+}
@@ -0,0 +1,154 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runNoUI starts app in NoUI mode and returns its ready link and Run's result.
+func runNoUI(t *testing.T, app *App) (string, <-chan error) {
+	t.Helper()
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	t.Setenv("ELETROCROMO_READY_FILE", readyFile)
+	app.NoUI = true
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	return waitReadyFile(t, readyFile), errCh
+}
+
+func waitRun(t *testing.T, errCh <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestShutdown_DrainsInFlightRequest(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	app := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		if _, err := io.WriteString(w, "done"); err != nil {
+			return
+		}
+	}), WithID("br.tec.lew.test.drain"), WithContext(ctx), WithShutdownTimeout(2*time.Second))
+
+	hookCalled := make(chan struct{})
+	app.RegisterOnShutdown(func() { close(hookCalled) })
+	link, errCh := runNoUI(t, app)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	client := sessionClient(t)
+	go func() {
+		resp, err := client.Get(link)
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		b, err := io.ReadAll(resp.Body)
+		resCh <- result{string(b), err}
+	}()
+	<-entered
+	cancel()
+	select {
+	case <-hookCalled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("RegisterOnShutdown hook was not called")
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	res := <-resCh
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request: body=%q err=%v", res.body, res.err)
+	}
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdown_DrainDeadlineClosesConnections(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	entered := make(chan struct{})
+	app := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done()
+	}), WithID("br.tec.lew.test.drain_deadline"), WithContext(ctx), WithShutdownTimeout(100*time.Millisecond))
+	link, errCh := runNoUI(t, app)
+
+	getErr := make(chan error, 1)
+	client := sessionClient(t)
+	go func() {
+		resp, err := client.Get(link)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		getErr <- err
+	}()
+	<-entered
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-getErr:
+		if err == nil {
+			t.Fatal("request past the drain deadline should fail")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection was not closed after the drain deadline")
+	}
+}
+
+func TestShutdown_TaskDeadlineNamesStragglers(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := New(http.NotFoundHandler(), WithID("br.tec.lew.test.task_deadline"),
+		WithContext(ctx), WithTaskTimeout(100*time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+
+	_, errCh := runNoUI(t, app)
+	if err := app.BackgroundRun(NamedTask("stubborn-sync", FunctionTask(func(context.Context) error {
+		<-release // ignores ctx
+		return nil
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BackgroundRun(NamedTask("polite", FunctionTask(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	err := waitRun(t, errCh)
+	if !errors.Is(err, ErrTasksDidNotStop) {
+		t.Fatalf("want ErrTasksDidNotStop, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "stubborn-sync") || strings.Contains(err.Error(), "polite") {
+		t.Fatalf("error should name only the stuck task: %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"time"
+)
+
+// ErrTaskPanic wraps a panic recovered from a background task.
+var ErrTaskPanic = errors.New("task panicked")
+
+// ErrRestartLimit is returned by a supervised task that failed again after
+// MaxRestarts restarts; the last failure is wrapped too.
+var ErrRestartLimit = errors.New("task restart limit reached")
+
+// Defaults for Supervision backoff.
+const (
+	DefaultRestartBackoff    = time.Second
+	DefaultMaxRestartBackoff = time.Minute
+	DefaultRestartJitter     = 0.2
+)
+
+// RestartPolicy says when a supervised task is run again.
+type RestartPolicy int
+
+const (
+	// RestartNever runs the task once (panics still become errors).
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restarts after an error or panic; a nil return ends it.
+	RestartOnFailure
+	// RestartAlways restarts whenever the task returns, until cancel.
+	RestartAlways
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+	return "unknown"
+}
+
+// Supervision configures Supervise. The zero value runs the task once.
+type Supervision struct {
+	Restart RestartPolicy
+	// MaxRestarts caps restarts; zero means unlimited. Past it the task
+	// returns ErrRestartLimit wrapping the last error.
+	MaxRestarts int
+	// Backoff is the delay before the first restart, doubled after each
+	// one up to MaxBackoff. Zero means DefaultRestartBackoff and
+	// DefaultMaxRestartBackoff. A run that lasts longer than MaxBackoff
+	// resets the delay (but not the MaxRestarts count).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter spreads each delay by ±Jitter (a fraction, 0–1) so restarting
+	// tasks do not move in lockstep. Zero means DefaultRestartJitter;
+	// negative disables it.
+	Jitter float64
+}
+
+// Supervise wraps task so BackgroundRun restarts it per s. The result is
+// named name in shutdown errors and logs. It stops cleanly (nil) once the
+// run context is cancelled, including during a backoff wait.
+func Supervise(name string, task Task, s Supervision) Task {
+	return &supervisedTask{name: name, task: task, s: s}
+}
+
+type supervisedTask struct {
+	name string
+	task Task
+	s    Supervision
+}
+
+func (t *supervisedTask) Name() string { return t.name }
+
+func (t *supervisedTask) Unwrap() Task { return t.task }
+
+func (t *supervisedTask) Run(ctx context.Context) error {
+	lg := taskLogger(ctx).With("task", t.name)
+	delay := t.backoff()
+	restarts := 0
+	for {
+		started := time.Now()
+		err := runTask(ctx, t.task)
+		if ctx.Err() != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case t.s.Restart == RestartNever:
+			return err
+		case t.s.Restart == RestartOnFailure && err == nil:
+			return nil
+		}
+		if t.s.MaxRestarts > 0 && restarts >= t.s.MaxRestarts {
+			if err == nil {
+				return nil
+			}
+			return fmt.Errorf("%w (%d): %w", ErrRestartLimit, restarts, err)
+		}
+		if time.Since(started) > t.maxBackoff() {
+			delay = t.backoff()
+		}
+		wait := jitter(delay, t.s.Jitter)
+		restarts++
+		lg.Warn("task ended; restarting", "err", err, "restart", restarts, "delay", wait)
+		taskRestarting(ctx, err)
+		if !sleepCtx(ctx, wait) {
+			return nil
+		}
+		taskRunning(ctx)
+		delay = min(delay*2, t.maxBackoff())
+	}
+}
+
+func (t *supervisedTask) backoff() time.Duration {
+	if t.s.Backoff > 0 {
+		return t.s.Backoff
+	}
+	return DefaultRestartBackoff
+}
+
+func (t *supervisedTask) maxBackoff() time.Duration {
+	if t.s.MaxBackoff > 0 {
+		return max(t.s.MaxBackoff, t.backoff())
+	}
+	return max(DefaultMaxRestartBackoff, t.backoff())
+}
+
+// jitter spreads d by ±frac (DefaultRestartJitter when zero, none when
+// negative).
+func jitter(d time.Duration, frac float64) time.Duration {
+	if frac == 0 {
+		frac = DefaultRestartJitter
+	}
+	if frac < 0 {
+		return d
+	}
+	frac = min(frac, 1)
+	return time.Duration(float64(d) * (1 + frac*(2*rand.Float64()-1)))
+}
+
+// runTask runs task, turning a panic into an ErrTaskPanic error.
+func runTask(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v\n%s", ErrTaskPanic, r, debug.Stack())
+		}
+	}()
+	return task.Run(ctx)
+}
+
+type taskLoggerKey struct{}
+
+// taskLogger is the App logger BackgroundRun put on ctx (slog.Default
+// outside BackgroundRun).
+func taskLogger(ctx context.Context) *slog.Logger {
+	if lg, ok := ctx.Value(taskLoggerKey{}).(*slog.Logger); ok {
+		return lg
+	}
+	return slog.Default()
+}
@@ -0,0 +1,143 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastSupervision(policy RestartPolicy, maxRestarts int) Supervision {
+	return Supervision{Restart: policy, MaxRestarts: maxRestarts, Backoff: time.Millisecond, Jitter: -1}
+}
+
+func TestSupervise_RestartsOnFailureUntilSuccess(t *testing.T) {
+	var runs atomic.Int32
+	task := Supervise("flaky", FunctionTask(func(context.Context) error {
+		if runs.Add(1) < 3 {
+			return ErrTestBoom
+		}
+		return nil
+	}), fastSupervision(RestartOnFailure, 0))
+	if err := task.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() != 3 {
+		t.Fatalf("runs = %d, want 3", runs.Load())
+	}
+	if taskName(task) != "flaky" {
+		t.Fatalf("name = %q", taskName(task))
+	}
+}
+
+func TestSupervise_RestartLimit(t *testing.T) {
+	var runs atomic.Int32
+	task := Supervise("doomed", FunctionTask(func(context.Context) error {
+		runs.Add(1)
+		return ErrTestBoom
+	}), fastSupervision(RestartOnFailure, 2))
+	err := task.Run(t.Context())
+	if !errors.Is(err, ErrRestartLimit) || !errors.Is(err, ErrTestBoom) {
+		t.Fatalf("err = %v", err)
+	}
+	if runs.Load() != 3 {
+		t.Fatalf("runs = %d, want 1 + 2 restarts", runs.Load())
+	}
+}
+
+func TestSupervise_NeverAndPanic(t *testing.T) {
+	var runs atomic.Int32
+	task := Supervise("once", FunctionTask(func(context.Context) error {
+		runs.Add(1)
+		panic("kaboom")
+	}), Supervision{})
+	err := task.Run(t.Context())
+	if !errors.Is(err, ErrTaskPanic) {
+		t.Fatalf("err = %v", err)
+	}
+	if runs.Load() != 1 {
+		t.Fatalf("runs = %d", runs.Load())
+	}
+
+	// On-failure treats a panic as a failure.
+	runs.Store(0)
+	task = Supervise("recovers", FunctionTask(func(context.Context) error {
+		if runs.Add(1) == 1 {
+			panic("kaboom")
+		}
+		return nil
+	}), fastSupervision(RestartOnFailure, 0))
+	if err := task.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() != 2 {
+		t.Fatalf("runs = %d", runs.Load())
+	}
+}
+
+func TestSupervise_AlwaysStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	var runs atomic.Int32
+	task := Supervise("loop", FunctionTask(func(context.Context) error {
+		if runs.Add(1) == 3 {
+			cancel()
+		}
+		return nil
+	}), fastSupervision(RestartAlways, 0))
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() != 3 {
+		t.Fatalf("runs = %d", runs.Load())
+	}
+
+	// Cancel during a long backoff returns promptly.
+	ctx, cancel = context.WithCancel(t.Context())
+	task = Supervise("slow", FunctionTask(func(context.Context) error {
+		return ErrTestBoom
+	}), Supervision{Restart: RestartOnFailure, Backoff: time.Hour})
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("backoff did not observe cancel")
+	}
+}
+
+func TestSupervise_BackoffGrowsWithJitter(t *testing.T) {
+	for range 100 {
+		d := jitter(time.Second, 0.2)
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("jitter out of range: %v", d)
+		}
+	}
+	if d := jitter(time.Second, -1); d != time.Second {
+		t.Fatalf("disabled jitter: %v", d)
+	}
+
+	var stamps []time.Time
+	task := Supervise("backoff", FunctionTask(func(context.Context) error {
+		stamps = append(stamps, time.Now())
+		return ErrTestBoom
+	}), Supervision{Restart: RestartOnFailure, MaxRestarts: 3, Backoff: 10 * time.Millisecond, Jitter: -1})
+	if err := task.Run(t.Context()); !errors.Is(err, ErrRestartLimit) {
+		t.Fatal(err)
+	}
+	// 10ms, 20ms, 40ms.
+	if gap := stamps[3].Sub(stamps[2]); gap < 40*time.Millisecond {
+		t.Fatalf("third backoff = %v, want ≥ 40ms", gap)
+	}
+}
+
+func TestBackgroundRun_RecoversPanic(t *testing.T) {
+	app := afterRun(&App{Context: t.Context()})
+	if err := app.BackgroundRun(FunctionTask(func(context.Context) error {
+		panic("kaboom")
+	})); err != nil {
+		t.Fatal(err)
+	}
+	app.WaitGroup.Wait()
+}
@@ -0,0 +1,126 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// TaskError is a background task failure recorded by BackgroundRun.
+type TaskError struct {
+	Task     string // taskName
+	Critical bool
+	Time     time.Time
+	Err      error
+}
+
+func (e *TaskError) Error() string {
+	if e.Critical {
+		return fmt.Sprintf("critical task %s: %v", e.Task, e.Err)
+	}
+	return fmt.Sprintf("task %s: %v", e.Task, e.Err)
+}
+
+func (e *TaskError) Unwrap() error { return e.Err }
+
+// Critical marks task as critical: if it fails, BackgroundRun cancels the
+// running App (as if its context were cancelled) and Run returns the error,
+// joined with every other task error, via errors.Join. Returning because of
+// cancellation is not a failure. It holds through wrappers such as
+// NamedTask and Supervise (any Task with an Unwrap() Task method), so
+// NamedTask(Critical(…)) is critical too; inside Supervise only the failure
+// Supervise finally returns counts, not the ones it restarts after.
+func Critical(task Task) Task {
+	return criticalTask{Task: task}
+}
+
+type criticalTask struct {
+	Task
+}
+
+func (t criticalTask) Name() string { return taskName(t.Task) }
+
+func (t criticalTask) Unwrap() Task { return t.Task }
+
+// isCritical reports whether task or any task it wraps is Critical.
+func isCritical(task Task) bool {
+	for task != nil {
+		if _, ok := task.(criticalTask); ok {
+			return true
+		}
+		u, ok := task.(interface{ Unwrap() Task })
+		if !ok {
+			return false
+		}
+		task = u.Unwrap()
+	}
+	return false
+}
+
+// TaskErrors returns the task failures recorded since the last Run
+// returned (the current Run's, and those of tasks that failed before it),
+// oldest first. Non-critical failures only show up here (and in the log).
+func (a *App) TaskErrors() []*TaskError {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.taskErrs)
+}
+
+// recordTaskError keeps a task failure and, for a critical task, cancels
+// the current Run.
+func (a *App) recordTaskError(ctx context.Context, task Task, err error) *TaskError {
+	if ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return nil
+	}
+	te := &TaskError{Task: taskName(task), Critical: isCritical(task), Time: time.Now(), Err: err}
+	a.mu.Lock()
+	a.taskErrs = append(a.taskErrs, te)
+	cancel := a.cancelRun
+	a.mu.Unlock()
+	if te.Critical && cancel != nil {
+		cancel()
+	}
+	return te
+}
+
+// runTaskErr is Run's task error: every recorded failure joined, or nil when
+// no critical task failed.
+func (a *App) runTaskErr() error {
+	errs := a.TaskErrors()
+	if !slices.ContainsFunc(errs, func(e *TaskError) bool { return e.Critical }) {
+		return nil
+	}
+	joined := make([]error, len(errs))
+	for i, e := range errs {
+		joined[i] = e
+	}
+	return errors.Join(joined...)
+}
+
+// startRunTasks drops the task failures a previous Run already returned,
+// holds BackgroundRun calls in the queue until Run starts it and publishes
+// cancel for critical tasks; the returned func unpublishes it. Tasks still
+// queued (Run failed before starting them) wait for the next Run. A critical
+// task that failed before Run cancels it at once.
+func (a *App) startRunTasks(cancel context.CancelFunc) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.taskErrs = slices.Clone(a.taskErrs[a.reportedTaskErrs:])
+	a.reportedTaskErrs = 0
+	a.resetFinishedTasks()
+	a.runStarting = true
+	a.ran = true
+	a.cancelRun = cancel
+	if slices.ContainsFunc(a.taskErrs, func(e *TaskError) bool { return e.Critical }) {
+		cancel()
+	}
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.cancelRun = nil
+		a.runStarting = false
+		a.reportedTaskErrs = len(a.taskErrs)
+	}
+}
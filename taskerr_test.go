@@ -0,0 +1,149 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var errMigration = errors.New("migration failed")
+
+func TestRun_CriticalTaskFailsRun(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.critical"),
+		WithContext(t.Context()),
+	)
+	_, errCh := runNoUI(t, app)
+
+	if err := app.BackgroundRun(NamedTask("thumbnails", FunctionTask(func(context.Context) error {
+		return ErrTestBoom
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	// Let the non-critical failure land first; Run must keep serving.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(app.TaskErrors()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("non-critical failure stopped Run: %v", err)
+	default:
+	}
+
+	if err := app.BackgroundRun(Critical(NamedTask("migrate", FunctionTask(func(context.Context) error {
+		return errMigration
+	})))); err != nil {
+		t.Fatal(err)
+	}
+	err := waitRun(t, errCh)
+	if !errors.Is(err, errMigration) || !errors.Is(err, ErrTestBoom) {
+		t.Fatalf("Run err = %v, want both task errors", err)
+	}
+	var te *TaskError
+	if !errors.As(err, &te) || te.Task != "thumbnails" {
+		t.Fatalf("want TaskError for thumbnails first, got %v", err)
+	}
+
+	errs := app.TaskErrors()
+	if len(errs) != 2 || errs[0].Critical || !errs[1].Critical || errs[1].Task != "migrate" {
+		t.Fatalf("TaskErrors = %v", errs)
+	}
+}
+
+func TestRun_NonCriticalFailuresAreNotReturned(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.noncritical"),
+		WithContext(ctx),
+	)
+	_, errCh := runNoUI(t, app)
+	if err := app.BackgroundRun(FunctionTask(func(context.Context) error { return ErrTestBoom })); err != nil {
+		t.Fatal(err)
+	}
+	// A critical task stopped by cancellation is not a failure.
+	if err := app.BackgroundRun(Critical(FunctionTask(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatalf("Run err = %v", err)
+	}
+	if errs := app.TaskErrors(); len(errs) != 1 || !errors.Is(errs[0], ErrTestBoom) {
+		t.Fatalf("TaskErrors = %v", errs)
+	}
+}
+
+func TestIsCritical_ThroughWrappers(t *testing.T) {
+	fn := FunctionTask(func(context.Context) error { return nil })
+	for name, task := range map[string]Task{
+		"named":               NamedTask("migrate", Critical(fn)),
+		"supervised":          Supervise("migrate", Critical(fn), Supervision{}),
+		"named in supervised": Supervise("sync", NamedTask("migrate", Critical(fn)), Supervision{}),
+		"outermost":           Critical(NamedTask("migrate", fn)),
+	} {
+		if !isCritical(task) {
+			t.Errorf("%s: not critical", name)
+		}
+	}
+	if isCritical(NamedTask("thumbnails", Supervise("t", fn, Supervision{}))) {
+		t.Error("plain wrappers reported critical")
+	}
+}
+
+func TestRun_KeepsTaskErrorsFromBeforeRun(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.prerun_errors"),
+		WithContext(ctx),
+	)
+	// After a Run returned, BackgroundRun starts tasks at once.
+	_, errCh := runNoUI(t, app)
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	app.Context = t.Context()
+	if err := app.BackgroundRun(NamedTask("warmup", FunctionTask(func(context.Context) error {
+		return ErrTestBoom
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BackgroundRun(NamedTask("migrate", Critical(FunctionTask(func(context.Context) error {
+		return errMigration
+	})))); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(app.TaskErrors()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The critical failure happened before Run: Run stops at once with both.
+	_, errCh = runNoUI(t, app)
+	err := waitRun(t, errCh)
+	if !errors.Is(err, errMigration) || !errors.Is(err, ErrTestBoom) {
+		t.Fatalf("Run err = %v, want the pre-Run task errors", err)
+	}
+
+	// Once returned, they do not fail the next Run.
+	ctx, cancel = context.WithCancel(t.Context())
+	app.Context = ctx
+	_, errCh = runNoUI(t, app)
+	if errs := app.TaskErrors(); len(errs) != 0 {
+		t.Fatalf("TaskErrors in the next Run = %v", errs)
+	}
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatalf("next Run err = %v", err)
+	}
+}
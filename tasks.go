@@ -0,0 +1,228 @@
+package eletrocromo
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// TasksPath is the reserved, auth-gated endpoint serving Tasks as JSON.
+// Requests under ReservedPathPrefix never reach App.Handler.
+const (
+	ReservedPathPrefix = "/__eletrocromo/"
+	TasksPath          = ReservedPathPrefix + "tasks"
+)
+
+// maxFinishedTasks bounds how many finished tasks Tasks keeps reporting.
+const maxFinishedTasks = 100
+
+// TaskState is a BackgroundRun task's state in TaskInfo.
+type TaskState string
+
+const (
+	TaskQueued     TaskState = "queued" // waiting for Run to start it (see App.TaskStart)
+	TaskRunning    TaskState = "running"
+	TaskRestarting TaskState = "restarting" // Supervise backoff
+	TaskDone       TaskState = "done"       // returned nil or was cancelled
+	TaskFailed     TaskState = "failed"
+)
+
+// TaskInfo is a snapshot of one BackgroundRun task.
+type TaskInfo struct {
+	Name      string    `json:"name"`
+	State     TaskState `json:"state"`
+	Critical  bool      `json:"critical"`
+	Started   time.Time `json:"started"`
+	Ended     time.Time `json:"ended,omitzero"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// taskEntry is the live record behind TaskInfo; fields are guarded by App.mu.
+type taskEntry struct {
+	id   uint64
+	info TaskInfo
+}
+
+// Tasks lists running tasks and the latest finished ones of the current
+// Run, oldest first.
+func (a *App) Tasks() []TaskInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries := make([]*taskEntry, 0, len(a.tasks))
+	for _, e := range a.tasks {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(x, y *taskEntry) int { return cmp.Compare(x.id, y.id) })
+	infos := make([]TaskInfo, len(entries))
+	for i, e := range entries {
+		infos[i] = e.info
+	}
+	return infos
+}
+
+// newTaskEntry registers a BackgroundRun task. Caller holds mu.
+func (a *App) newTaskEntry(name string, critical bool, state TaskState) *taskEntry {
+	if a.tasks == nil {
+		a.tasks = make(map[uint64]*taskEntry)
+	}
+	a.taskSeq++
+	e := &taskEntry{id: a.taskSeq, info: TaskInfo{
+		Name:     name,
+		State:    state,
+		Critical: critical,
+	}}
+	if state == TaskRunning {
+		e.info.Started = time.Now()
+	}
+	a.tasks[e.id] = e
+	return e
+}
+
+// TaskStartPolicy is App.TaskStart.
+type TaskStartPolicy int
+
+const (
+	// TaskStartOnReady starts queued tasks once the server accepts
+	// requests, before OnReady.
+	TaskStartOnReady TaskStartPolicy = iota
+	// TaskStartOnWindow starts them once the first window opened (on
+	// ready in NoUI mode); they never start if the window fails to launch.
+	TaskStartOnWindow
+	// TaskStartImmediately starts them as soon as the server is bound
+	// (and the instance lock held), before it accepts requests.
+	TaskStartImmediately
+)
+
+type queuedTask struct {
+	task  Task
+	entry *taskEntry
+}
+
+// queueTask registers task as queued for Run. Callers hold a.mu.
+func (a *App) queueTask(task Task) {
+	entry := a.newTaskEntry(taskName(task), isCritical(task), TaskQueued)
+	a.queuedTasks = append(a.queuedTasks, queuedTask{task: task, entry: entry})
+}
+
+// startQueuedTasks starts the queued tasks on Run's ctx; from now on
+// BackgroundRun starts tasks at once.
+func (a *App) startQueuedTasks(ctx context.Context) {
+	a.mu.Lock()
+	queued := a.queuedTasks
+	a.queuedTasks = nil
+	a.runStarting = false
+	now := time.Now()
+	for _, q := range queued {
+		q.entry.info.State = TaskRunning
+		q.entry.info.Started = now
+	}
+	a.mu.Unlock()
+	for _, q := range queued {
+		a.startTask(ctx, q.task, q.entry)
+	}
+}
+
+// finishTask marks e done or failed and drops the oldest finished entries
+// past maxFinishedTasks. A nil err (or cancellation) is done.
+func (a *App) finishTask(e *taskEntry, te *TaskError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.info.Ended = time.Now()
+	e.info.State = TaskDone
+	if te != nil {
+		e.info.State = TaskFailed
+		e.info.LastError = te.Err.Error()
+	}
+	var finished []uint64
+	for id, t := range a.tasks {
+		if !t.info.Ended.IsZero() {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) > maxFinishedTasks {
+		slices.Sort(finished)
+		for _, id := range finished[:len(finished)-maxFinishedTasks] {
+			delete(a.tasks, id)
+		}
+	}
+}
+
+// runningTaskNames names the tasks still running (for ErrTasksDidNotStop).
+func (a *App) runningTaskNames() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var names []string
+	for _, e := range a.tasks {
+		if e.info.Ended.IsZero() && e.info.State != TaskQueued {
+			names = append(names, e.info.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// resetFinishedTasks forgets tasks that ended before this Run.
+func (a *App) resetFinishedTasks() {
+	for id, e := range a.tasks {
+		if !e.info.Ended.IsZero() {
+			delete(a.tasks, id)
+		}
+	}
+}
+
+type taskEntryKey struct{}
+
+// taskRestarting and taskRunning let Supervise report into the registry of
+// the BackgroundRun that started it (no-ops outside BackgroundRun).
+func taskRestarting(ctx context.Context, err error) {
+	taskUpdate(ctx, func(info *TaskInfo) {
+		info.State = TaskRestarting
+		info.Restarts++
+		if err != nil {
+			info.LastError = err.Error()
+		}
+	})
+}
+
+func taskRunning(ctx context.Context) {
+	taskUpdate(ctx, func(info *TaskInfo) { info.State = TaskRunning })
+}
+
+type taskHandle struct {
+	a *App
+	e *taskEntry
+}
+
+func taskUpdate(ctx context.Context, f func(*TaskInfo)) {
+	h, ok := ctx.Value(taskEntryKey{}).(taskHandle)
+	if !ok {
+		return
+	}
+	h.a.mu.Lock()
+	defer h.a.mu.Unlock()
+	f(&h.e.info)
+}
+
+// serveReserved answers ReservedPathPrefix requests (already authenticated).
+func (a *App) serveReserved(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != TasksPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(struct {
+		Tasks []TaskInfo `json:"tasks"`
+	}{a.Tasks()}); err != nil {
+		a.logger(phaseServe).Warn("tasks endpoint", "err", err)
+	}
+}
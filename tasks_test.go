@@ -0,0 +1,257 @@
+package eletrocromo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitTaskState(t *testing.T, app *App, name string, state TaskState) TaskInfo {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, info := range app.Tasks() {
+			if info.Name == name && info.State == state {
+				return info
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("task %s never reached %s: %+v", name, state, app.Tasks())
+	return TaskInfo{}
+}
+
+func TestTasks_ReportsStateRestartsAndErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	app := afterRun(&App{Context: ctx})
+
+	release := make(chan struct{})
+	if err := app.BackgroundRun(NamedTask("sync", FunctionTask(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BackgroundRun(NamedTask("import", FunctionTask(func(context.Context) error {
+		return ErrTestBoom
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BackgroundRun(Supervise("watcher", FunctionTask(func(context.Context) error {
+		return ErrTestBoom
+	}), Supervision{Restart: RestartOnFailure, Backoff: time.Hour})); err != nil {
+		t.Fatal(err)
+	}
+
+	running := waitTaskState(t, app, "sync", TaskRunning)
+	if running.Started.IsZero() || !running.Ended.IsZero() {
+		t.Fatalf("sync = %+v", running)
+	}
+	failed := waitTaskState(t, app, "import", TaskFailed)
+	if failed.LastError != ErrTestBoom.Error() || failed.Ended.IsZero() {
+		t.Fatalf("import = %+v", failed)
+	}
+	restarting := waitTaskState(t, app, "watcher", TaskRestarting)
+	if restarting.Restarts != 1 || restarting.LastError != ErrTestBoom.Error() {
+		t.Fatalf("watcher = %+v", restarting)
+	}
+
+	close(release)
+	waitTaskState(t, app, "sync", TaskDone)
+	cancel()
+	app.WaitGroup.Wait()
+	if got := app.Tasks(); len(got) != 3 || got[0].Name != "sync" || got[2].State != TaskDone {
+		t.Fatalf("Tasks = %+v", got)
+	}
+}
+
+func TestServeHTTP_TasksEndpoint(t *testing.T) {
+	reached := false
+	app := afterRun(&App{
+		AuthToken: "secret-token",
+		Context:   t.Context(),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = true
+		}),
+	})
+	if err := app.BackgroundRun(NamedTask("import", FunctionTask(func(context.Context) error {
+		return ErrTestBoom
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	app.WaitGroup.Wait()
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, TasksPath, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodGet, TasksPath, "", "secret-token"))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Tasks []TaskInfo `json:"tasks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Tasks) != 1 || body.Tasks[0].Name != "import" || body.Tasks[0].State != TaskFailed {
+		t.Fatalf("tasks = %+v", body.Tasks)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodPost, TasksPath, "", "secret-token"))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, newAuthRequest(http.MethodGet, ReservedPathPrefix+"nope", "", "secret-token"))
+	if w.Code != http.StatusNotFound || reached {
+		t.Fatalf("reserved prefix reached the app handler (%d)", w.Code)
+	}
+}
+
+func TestBackgroundRun_QueuedUntilReadyWithRunContext(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	stopped := make(chan struct{})
+	var listening atomic.Bool
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.queued"),
+		WithContext(t.Context()), // never cancelled: only Run's own context can stop the task
+	)
+	if err := app.BackgroundRun(NamedTask("sync", FunctionTask(func(ctx context.Context) error {
+		app.mu.Lock()
+		listening.Store(app.boundHost != "")
+		app.mu.Unlock()
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.Tasks(); len(got) != 1 || got[0].State != TaskQueued {
+		t.Fatalf("before Run: %+v", got)
+	}
+
+	_, errCh := runNoUI(t, app)
+	waitTaskState(t, app, "sync", TaskRunning)
+	// Stand-in for the window closing: cancel Run's own context.
+	app.mu.Lock()
+	cancelRun := app.cancelRun
+	app.mu.Unlock()
+	cancelRun()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("queued task did not get Run's context")
+	}
+	if !listening.Load() {
+		t.Fatal("queued task started before the server was listening")
+	}
+}
+
+func TestBackgroundRun_OutsideRunStartsAtOnce(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.outside_run"),
+		WithContext(ctx),
+	)
+	_, errCh := runNoUI(t, app)
+	cancel()
+	if err := waitRun(t, errCh); err != nil {
+		t.Fatal(err)
+	}
+
+	// After Run returned there is no Run to wait for: the task must run.
+	ran := make(chan struct{})
+	if err := app.BackgroundRun(NamedTask("late", FunctionTask(func(context.Context) error {
+		close(ran)
+		return nil
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("BackgroundRun after Run returned never started the task")
+	}
+	if got := app.Tasks(); len(got) != 1 || got[0].State == TaskQueued {
+		t.Fatalf("Tasks = %+v", got)
+	}
+}
+
+func TestBackgroundRun_QueuedUntilWindowAndStopsWithIt(t *testing.T) {
+	script, _ := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+
+	var rec hookRecorder
+	app := New(http.NotFoundHandler(), append(rec.options(),
+		WithID("br.tec.lew.test.queued_window"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+	)...)
+	app.TaskStart = TaskStartOnWindow
+	if err := app.BackgroundRun(FunctionTask(func(ctx context.Context) error {
+		rec.add("task started")
+		<-ctx.Done()
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	// Window-owned: the window exits after 0.3s, and Run (with the task)
+	// must follow even though the parent context stays alive.
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	got := rec.snapshot()
+	if len(got) < 3 || got[0] != "ready" || got[1] != "opened" || got[2] != "task started" {
+		t.Fatalf("order = %q, want ready, opened, task started", got)
+	}
+}
+
+func TestBackgroundRun_QueuedPastFailedRun(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.queued_busy"),
+		WithContext(t.Context()),
+		WithPort(busy.Addr().(*net.TCPAddr).Port),
+		WithNoUI(true),
+		WithTaskStart(TaskStartImmediately),
+	)
+	var ran atomic.Bool
+	if err := app.BackgroundRun(NamedTask("migrate", FunctionTask(func(context.Context) error {
+		ran.Store(true)
+		return nil
+	}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Run(); !errors.Is(err, ErrPortInUse) {
+		t.Fatalf("want ErrPortInUse, got %v", err)
+	}
+	app.WaitGroup.Wait()
+	if ran.Load() {
+		t.Fatal("task started by a Run that never bound")
+	}
+	if got := app.Tasks(); len(got) != 1 || got[0].State != TaskQueued {
+		t.Fatalf("Tasks = %+v, want migrate still queued for the next Run", got)
+	}
+}
@@ -0,0 +1,134 @@
+package eletrocromo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrTokenName is returned by MintToken for an empty name.
+var ErrTokenName = errors.New("token name is required")
+
+// TokenScope limits what a minted token may do.
+type TokenScope int
+
+const (
+	// ScopeFull allows every method, like AuthToken.
+	ScopeFull TokenScope = iota
+	// ScopeReadOnly allows only safe methods (GET, HEAD, OPTIONS); other
+	// requests get 403.
+	ScopeReadOnly
+)
+
+func (s TokenScope) String() string {
+	switch s {
+	case ScopeFull:
+		return "full"
+	case ScopeReadOnly:
+		return "read-only"
+	}
+	return "unknown"
+}
+
+// SessionTokenName is AuthInfo.Name for requests authenticated with
+// AuthToken (the window's cookie, ?token=, or Bearer AuthToken).
+const SessionTokenName = "session"
+
+// AuthInfo describes how a request was authenticated; handlers read it with
+// AuthFromContext.
+type AuthInfo struct {
+	// Name is SessionTokenName or the name given to MintToken.
+	Name  string
+	Scope TokenScope
+	// Session is the browser session id when authenticated by the session
+	// cookie (pass it to RevokeSession); empty otherwise.
+	Session string
+}
+
+type authInfoKey struct{}
+
+// AuthFromContext returns the AuthInfo ServeHTTP attached to the request.
+func AuthFromContext(ctx context.Context) (AuthInfo, bool) {
+	info, ok := ctx.Value(authInfoKey{}).(AuthInfo)
+	return info, ok
+}
+
+type scopedToken struct {
+	hash  [sha256.Size]byte
+	scope TokenScope
+}
+
+// MintToken creates an extra token for non-browser clients (companion CLI,
+// scripts). It is accepted only as "Authorization: Bearer <token>", never
+// from the query or cookie. Minting an existing name replaces (rotates) its
+// token. Tokens live in memory: they last until RevokeToken or process exit.
+func (a *App) MintToken(name string, scope TokenScope) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrTokenName
+	}
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b[:])
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.scoped == nil {
+		a.scoped = make(map[string]scopedToken)
+	}
+	a.scoped[name] = scopedToken{hash: sha256.Sum256([]byte(token)), scope: scope}
+	return token, nil
+}
+
+// RevokeToken invalidates the named token at once (in-flight requests
+// finish). Reports whether it existed.
+func (a *App) RevokeToken(name string) bool {
+	name = strings.TrimSpace(name)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.scoped[name]
+	delete(a.scoped, name)
+	return ok
+}
+
+// lookupScoped matches a Bearer value against minted tokens by hash, so the
+// comparison does not leak token bytes through timing.
+func (a *App) lookupScoped(token string) (AuthInfo, bool) {
+	h := sha256.Sum256([]byte(token))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name, st := range a.scoped {
+		if st.hash == h {
+			return AuthInfo{Name: name, Scope: st.scope}, true
+		}
+	}
+	return AuthInfo{}, false
+}
+
+// bearerToken extracts the credentials of "Authorization: Bearer <token>".
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// allowedByScope reports whether scope permits r's method.
+func allowedByScope(scope TokenScope, r *http.Request) bool {
+	if scope != ScopeReadOnly {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
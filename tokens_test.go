@@ -0,0 +1,106 @@
+package eletrocromo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTP_BearerAndScopedTokens(t *testing.T) {
+	var seen AuthInfo
+	app := &App{
+		AuthToken: "secret-token",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info, ok := AuthFromContext(r.Context())
+			if !ok {
+				t.Error("no AuthInfo in request context")
+			}
+			seen = info
+			w.WriteHeader(http.StatusOK)
+		}),
+	}
+	cli, err := app.MintToken("cli", ScopeFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := app.MintToken("dashboard", ScopeReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		auth     string
+		cookie   string
+		want     int
+		wantName string
+	}{
+		{name: "bearer AuthToken", method: http.MethodPost, auth: "Bearer secret-token", want: http.StatusOK, wantName: SessionTokenName},
+		{name: "scheme is case-insensitive", method: http.MethodGet, auth: "bearer secret-token", want: http.StatusOK, wantName: SessionTokenName},
+		{name: "bearer wrong token", method: http.MethodGet, auth: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "bearer wins over cookie", method: http.MethodGet, auth: "Bearer nope", cookie: "secret-token", want: http.StatusUnauthorized},
+		{name: "basic scheme ignored", method: http.MethodGet, auth: "Basic secret-token", want: http.StatusUnauthorized},
+		{name: "named full token POST", method: http.MethodPost, auth: "Bearer " + cli, want: http.StatusOK, wantName: "cli"},
+		{name: "read-only GET", method: http.MethodGet, auth: "Bearer " + reader, want: http.StatusOK, wantName: "dashboard"},
+		{name: "read-only POST", method: http.MethodPost, auth: "Bearer " + reader, want: http.StatusForbidden},
+		{name: "read-only DELETE", method: http.MethodDelete, auth: "Bearer " + reader, want: http.StatusForbidden},
+		{name: "minted token not accepted as cookie", method: http.MethodGet, cookie: cli, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = AuthInfo{}
+			req := newAuthRequest(tt.method, "/", "", tt.cookie)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.wantName != "" && seen.Name != tt.wantName {
+				t.Fatalf("AuthInfo.Name = %q, want %q", seen.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestMintToken_RevokeAndRotate(t *testing.T) {
+	app := &App{AuthToken: "secret-token", Handler: http.NotFoundHandler()}
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	first, err := app.MintToken("cli", ScopeFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := app.MintToken("cli", ScopeFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := do(first); code != http.StatusUnauthorized {
+		t.Fatalf("rotated-out token: want 401, got %d", code)
+	}
+	if code := do(second); code != http.StatusNotFound {
+		t.Fatalf("current token: want handler's 404, got %d", code)
+	}
+	if !app.RevokeToken("cli") {
+		t.Fatal("RevokeToken reported the token missing")
+	}
+	if code := do(second); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: want 401, got %d", code)
+	}
+	if app.RevokeToken("cli") {
+		t.Fatal("second RevokeToken should report false")
+	}
+	if _, err := app.MintToken(" ", ScopeFull); !errors.Is(err, ErrTokenName) {
+		t.Fatalf("want ErrTokenName, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package eletrocromo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ErrTrayUnsupported is returned where no CGo-less tray exists (non-Linux).
+var ErrTrayUnsupported = errors.New("system tray is not supported on this platform")
+
+// ErrTrayUnavailable is returned when the session bus has no
+// StatusNotifierWatcher (no tray host running).
+var ErrTrayUnavailable = errors.New("no StatusNotifierWatcher on the session bus")
+
+// defaultTrayIcon is a freedesktop icon-theme name; override with App.TrayIcon.
+const defaultTrayIcon = "applications-internet"
+
+// TrayItem is an app-defined tray menu entry, shown between Open and Quit.
+// OnClick runs on its own goroutine.
+type TrayItem struct {
+	Label   string
+	OnClick func()
+}
+
+// trayConfig is what the platform tray needs from App; Open/Quit are wired
+// by the caller so the tray never touches Run state directly.
+type trayConfig struct {
+	id     string
+	title  string
+	icon   string
+	items  []TrayItem
+	onOpen func()
+	onQuit func()
+	// bus dials the D-Bus connection the tray lives on; nil means the
+	// session bus (Linux only).
+	bus func() (*dbus.Conn, error)
+}
+
+// startAppTray brings up the tray for a running app: Open relaunches the
+// Helium window (same profile and token link), Quit cancels Run. Failure is
+// logged, not fatal — the app still runs without a tray.
+func (a *App) startAppTray(ctx context.Context, rs *runState) {
+	title := a.TrayTitle
+	if title == "" {
+		title = a.ID
+	}
+	icon := a.TrayIcon
+	if icon == "" {
+		icon = defaultTrayIcon
+	}
+	cfg := trayConfig{
+		id:    a.ID,
+		title: title,
+		icon:  icon,
+		items: a.TrayItems,
+		onOpen: func() {
+			if err := rs.openWindow(); err != nil {
+				a.logger(phaseTray).Warn("tray open failed", "err", err)
+			}
+		},
+		onQuit: rs.cancel,
+	}
+	if err := startTray(ctx, cfg); err != nil {
+		a.logger(phaseTray).Warn("tray unavailable", "err", err)
+	}
+}
@@ -0,0 +1,408 @@
+//go:build linux && !android
+
+package eletrocromo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// StatusNotifierItem + DBusMenu names (freedesktop / KDE / Canonical specs).
+const (
+	sniInterface  = "org.kde.StatusNotifierItem"
+	sniPath       = dbus.ObjectPath("/StatusNotifierItem")
+	menuInterface = "com.canonical.dbusmenu"
+	menuPath      = dbus.ObjectPath("/MenuBar")
+	watcherName   = "org.kde.StatusNotifierWatcher"
+	watcherPath   = dbus.ObjectPath("/StatusNotifierWatcher")
+)
+
+// Fixed dbusmenu ids; app TrayItems start at menuFirstCustomID.
+const (
+	menuRootID        int32 = 0
+	menuOpenID        int32 = 1
+	menuQuitID        int32 = 2
+	menuSeparatorID   int32 = 3
+	menuFirstCustomID int32 = 4
+)
+
+// sessionBus dials the session bus without autolaunching a daemon (a tray
+// with no desktop session is simply unavailable).
+func sessionBus() (*dbus.Conn, error) {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth(nil); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// trayInstances makes the well-known bus name unique per process.
+var trayInstances atomic.Int32
+
+// startTray exports a StatusNotifierItem with a DBusMenu on the session bus
+// and registers it with the watcher. The connection closes when ctx is done.
+func startTray(ctx context.Context, cfg trayConfig) error {
+	dial := cfg.bus
+	if dial == nil {
+		dial = sessionBus
+	}
+	conn, err := dial()
+	if err != nil {
+		return fmt.Errorf("%w: session bus: %w", ErrTrayUnavailable, err)
+	}
+	name := fmt.Sprintf("org.kde.StatusNotifierItem-%d-%d", os.Getpid(), trayInstances.Add(1))
+	if err := exportTray(conn, name, cfg); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if err := registerTray(conn, name); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	// Tray hosts can restart (panel crash, session reload): re-register
+	// whenever the watcher name gets a new owner.
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, watcherName),
+	); err == nil {
+		signals := make(chan *dbus.Signal, 4)
+		conn.Signal(signals)
+		go func() {
+			for sig := range signals {
+				if len(sig.Body) == 3 {
+					if owner, ok := sig.Body[2].(string); ok && owner != "" {
+						_ = registerTray(conn, name)
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	return nil
+}
+
+func registerTray(conn *dbus.Conn, name string) error {
+	call := conn.Object(watcherName, watcherPath).Call(watcherName+".RegisterStatusNotifierItem", 0, name)
+	if call.Err != nil {
+		return fmt.Errorf("%w: %w", ErrTrayUnavailable, call.Err)
+	}
+	return nil
+}
+
+func exportTray(conn *dbus.Conn, name string, cfg trayConfig) error {
+	item := &sniItem{onActivate: cfg.onOpen}
+	if err := conn.Export(item, sniPath, sniInterface); err != nil {
+		return fmt.Errorf("tray: export item: %w", err)
+	}
+	if _, err := prop.Export(conn, sniPath, prop.Map{sniInterface: sniProps(cfg)}); err != nil {
+		return fmt.Errorf("tray: export item props: %w", err)
+	}
+	menu := newTrayMenu(cfg)
+	if err := conn.Export(menu, menuPath, menuInterface); err != nil {
+		return fmt.Errorf("tray: export menu: %w", err)
+	}
+	if _, err := prop.Export(conn, menuPath, prop.Map{menuInterface: {
+		"Version":       {Value: uint32(3), Emit: prop.EmitConst},
+		"TextDirection": {Value: "ltr", Emit: prop.EmitConst},
+		"Status":        {Value: "normal", Emit: prop.EmitConst},
+		"IconThemePath": {Value: []string{}, Emit: prop.EmitConst},
+	}}); err != nil {
+		return fmt.Errorf("tray: export menu props: %w", err)
+	}
+	if err := conn.Export(introspect.Introspectable(sniIntrospectXML), sniPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("tray: export introspection: %w", err)
+	}
+	if err := conn.Export(introspect.Introspectable(menuIntrospectXML), menuPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("tray: export introspection: %w", err)
+	}
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("tray: request name: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("tray: bus name %s already taken", name)
+	}
+	return nil
+}
+
+// sniToolTip is the (sa(iiay)ss) ToolTip property: icon, pixmaps, title, body.
+type sniToolTip struct {
+	IconName    string
+	Pixmaps     []sniPixmap
+	Title       string
+	Description string
+}
+
+type sniPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+func sniProps(cfg trayConfig) map[string]*prop.Prop {
+	constant := func(v any) *prop.Prop { return &prop.Prop{Value: v, Emit: prop.EmitConst} }
+	return map[string]*prop.Prop{
+		"Category":            constant("ApplicationStatus"),
+		"Id":                  constant(cfg.id),
+		"Title":               constant(cfg.title),
+		"Status":              constant("Active"),
+		"WindowId":            constant(int32(0)),
+		"IconName":            constant(cfg.icon),
+		"IconThemePath":       constant(""),
+		"OverlayIconName":     constant(""),
+		"AttentionIconName":   constant(""),
+		"AttentionMovieName":  constant(""),
+		"IconPixmap":          constant([]sniPixmap{}),
+		"OverlayIconPixmap":   constant([]sniPixmap{}),
+		"AttentionIconPixmap": constant([]sniPixmap{}),
+		"ToolTip":             constant(sniToolTip{IconName: cfg.icon, Pixmaps: []sniPixmap{}, Title: cfg.title}),
+		"ItemIsMenu":          constant(false),
+		"Menu":                constant(menuPath),
+	}
+}
+
+// sniItem implements org.kde.StatusNotifierItem methods. Left/middle click
+// opens the window; the context menu comes from DBusMenu.
+type sniItem struct {
+	onActivate func()
+}
+
+func (s *sniItem) Activate(x, y int32) *dbus.Error {
+	if s.onActivate != nil {
+		go s.onActivate()
+	}
+	return nil
+}
+
+func (s *sniItem) SecondaryActivate(x, y int32) *dbus.Error {
+	return s.Activate(x, y)
+}
+
+func (s *sniItem) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+func (s *sniItem) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+// menuLayout is the recursive (ia{sv}av) dbusmenu layout node.
+type menuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+// menuItemProps is one (ia{sv}) entry of GetGroupProperties.
+type menuItemProps struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}
+
+// menuEvent is one (isvu) entry of EventGroup.
+type menuEvent struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+type menuEntry struct {
+	id      int32
+	props   map[string]dbus.Variant
+	onClick func()
+}
+
+// trayMenu is a static com.canonical.dbusmenu: Open, app items, Quit.
+type trayMenu struct {
+	entries []menuEntry
+}
+
+func newTrayMenu(cfg trayConfig) *trayMenu {
+	label := func(s string) map[string]dbus.Variant {
+		return map[string]dbus.Variant{"label": dbus.MakeVariant(s)}
+	}
+	m := &trayMenu{}
+	m.entries = append(m.entries, menuEntry{id: menuOpenID, props: label("Open"), onClick: cfg.onOpen})
+	for i, it := range cfg.items {
+		m.entries = append(m.entries, menuEntry{id: menuFirstCustomID + int32(i), props: label(it.Label), onClick: it.OnClick})
+	}
+	if len(cfg.items) > 0 {
+		m.entries = append(m.entries, menuEntry{id: menuSeparatorID, props: map[string]dbus.Variant{
+			"type": dbus.MakeVariant("separator"),
+		}})
+	}
+	m.entries = append(m.entries, menuEntry{id: menuQuitID, props: label("Quit"), onClick: cfg.onQuit})
+	return m
+}
+
+func (m *trayMenu) entry(id int32) (menuEntry, bool) {
+	for _, e := range m.entries {
+		if e.id == id {
+			return e, true
+		}
+	}
+	return menuEntry{}, false
+}
+
+// filterProps keeps only names (all when names is empty), per dbusmenu.
+func filterProps(props map[string]dbus.Variant, names []string) map[string]dbus.Variant {
+	if len(names) == 0 {
+		return props
+	}
+	out := make(map[string]dbus.Variant, len(names))
+	for _, n := range names {
+		if v, ok := props[n]; ok {
+			out[n] = v
+		}
+	}
+	return out
+}
+
+func (m *trayMenu) GetLayout(parentID, recursionDepth int32, propertyNames []string) (uint32, menuLayout, *dbus.Error) {
+	if parentID != menuRootID {
+		e, ok := m.entry(parentID)
+		if !ok {
+			return 0, menuLayout{}, dbus.MakeFailedError(fmt.Errorf("unknown menu id %d", parentID))
+		}
+		return 1, menuLayout{ID: e.id, Properties: filterProps(e.props, propertyNames), Children: []dbus.Variant{}}, nil
+	}
+	root := menuLayout{
+		ID:         menuRootID,
+		Properties: map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")},
+		Children:   []dbus.Variant{},
+	}
+	if recursionDepth != 0 {
+		for _, e := range m.entries {
+			root.Children = append(root.Children, dbus.MakeVariant(menuLayout{
+				ID:         e.id,
+				Properties: filterProps(e.props, propertyNames),
+				Children:   []dbus.Variant{},
+			}))
+		}
+	}
+	return 1, root, nil
+}
+
+func (m *trayMenu) GetGroupProperties(ids []int32, propertyNames []string) ([]menuItemProps, *dbus.Error) {
+	out := []menuItemProps{}
+	for _, e := range m.entries {
+		if len(ids) > 0 && !containsID(ids, e.id) {
+			continue
+		}
+		out = append(out, menuItemProps{ID: e.id, Properties: filterProps(e.props, propertyNames)})
+	}
+	return out, nil
+}
+
+func (m *trayMenu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	e, ok := m.entry(id)
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(fmt.Errorf("unknown menu id %d", id))
+	}
+	v, ok := e.props[name]
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(fmt.Errorf("unknown property %q", name))
+	}
+	return v, nil
+}
+
+func (m *trayMenu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID != "clicked" {
+		return nil
+	}
+	e, ok := m.entry(id)
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("unknown menu id %d", id))
+	}
+	if e.onClick != nil {
+		go e.onClick()
+	}
+	return nil
+}
+
+func (m *trayMenu) EventGroup(events []menuEvent) ([]int32, *dbus.Error) {
+	idErrors := []int32{}
+	for _, ev := range events {
+		if err := m.Event(ev.ID, ev.EventID, ev.Data, ev.Timestamp); err != nil {
+			idErrors = append(idErrors, ev.ID)
+		}
+	}
+	return idErrors, nil
+}
+
+func (m *trayMenu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+func (m *trayMenu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
+
+func containsID(ids []int32, id int32) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+const sniIntrospectXML = `<node>
+  <interface name="org.kde.StatusNotifierItem">
+    <method name="Activate"><arg name="x" type="i" direction="in"/><arg name="y" type="i" direction="in"/></method>
+    <method name="SecondaryActivate"><arg name="x" type="i" direction="in"/><arg name="y" type="i" direction="in"/></method>
+    <method name="ContextMenu"><arg name="x" type="i" direction="in"/><arg name="y" type="i" direction="in"/></method>
+    <method name="Scroll"><arg name="delta" type="i" direction="in"/><arg name="orientation" type="s" direction="in"/></method>
+    <property name="Category" type="s" access="read"/>
+    <property name="Id" type="s" access="read"/>
+    <property name="Title" type="s" access="read"/>
+    <property name="Status" type="s" access="read"/>
+    <property name="WindowId" type="i" access="read"/>
+    <property name="IconName" type="s" access="read"/>
+    <property name="IconThemePath" type="s" access="read"/>
+    <property name="IconPixmap" type="a(iiay)" access="read"/>
+    <property name="OverlayIconName" type="s" access="read"/>
+    <property name="OverlayIconPixmap" type="a(iiay)" access="read"/>
+    <property name="AttentionIconName" type="s" access="read"/>
+    <property name="AttentionIconPixmap" type="a(iiay)" access="read"/>
+    <property name="AttentionMovieName" type="s" access="read"/>
+    <property name="ToolTip" type="(sa(iiay)ss)" access="read"/>
+    <property name="ItemIsMenu" type="b" access="read"/>
+    <property name="Menu" type="o" access="read"/>
+  </interface>` + prop.IntrospectDataString + introspect.IntrospectDataString + `</node>`
+
+const menuIntrospectXML = `<node>
+  <interface name="com.canonical.dbusmenu">
+    <method name="GetLayout"><arg name="parentId" type="i" direction="in"/><arg name="recursionDepth" type="i" direction="in"/><arg name="propertyNames" type="as" direction="in"/><arg name="revision" type="u" direction="out"/><arg name="layout" type="(ia{sv}av)" direction="out"/></method>
+    <method name="GetGroupProperties"><arg name="ids" type="ai" direction="in"/><arg name="propertyNames" type="as" direction="in"/><arg name="properties" type="a(ia{sv})" direction="out"/></method>
+    <method name="GetProperty"><arg name="id" type="i" direction="in"/><arg name="name" type="s" direction="in"/><arg name="value" type="v" direction="out"/></method>
+    <method name="Event"><arg name="id" type="i" direction="in"/><arg name="eventId" type="s" direction="in"/><arg name="data" type="v" direction="in"/><arg name="timestamp" type="u" direction="in"/></method>
+    <method name="EventGroup"><arg name="events" type="a(isvu)" direction="in"/><arg name="idErrors" type="ai" direction="out"/></method>
+    <method name="AboutToShow"><arg name="id" type="i" direction="in"/><arg name="needUpdate" type="b" direction="out"/></method>
+    <method name="AboutToShowGroup"><arg name="ids" type="ai" direction="in"/><arg name="updatesNeeded" type="ai" direction="out"/><arg name="idErrors" type="ai" direction="out"/></method>
+    <signal name="ItemsPropertiesUpdated"><arg name="updatedProps" type="a(ia{sv})"/><arg name="removedProps" type="a(ias)"/></signal>
+    <signal name="LayoutUpdated"><arg name="revision" type="u"/><arg name="parent" type="i"/></signal>
+    <property name="Version" type="u" access="read"/>
+    <property name="TextDirection" type="s" access="read"/>
+    <property name="Status" type="s" access="read"/>
+    <property name="IconThemePath" type="as" access="read"/>
+  </interface>` + prop.IntrospectDataString + introspect.IntrospectDataString + `</node>`
@@ -0,0 +1,200 @@
+//go:build linux && !android
+
+package eletrocromo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%DIR%</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a throwaway dbus-daemon and returns its address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	dir, err := os.MkdirTemp("", "ecbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	cfg := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(cfg, []byte(strings.ReplaceAll(testBusConfig, "%DIR%", dir)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+cfg, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+// busAt is a trayConfig.bus that dials addr.
+func busAt(addr string) func() (*dbus.Conn, error) {
+	return func() (*dbus.Conn, error) { return dbus.Connect(addr) }
+}
+
+// fakeWatcher stands in for the panel's StatusNotifierWatcher.
+type fakeWatcher struct {
+	registered chan string
+}
+
+func (w *fakeWatcher) RegisterStatusNotifierItem(service string) *dbus.Error {
+	w.registered <- service
+	return nil
+}
+
+func startFakeWatcher(t *testing.T, addr string) *fakeWatcher {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	w := &fakeWatcher{registered: make(chan string, 4)}
+	if err := conn.Export(w, watcherPath, watcherName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.RequestName(watcherName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func waitCalled(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s was not called", what)
+	}
+}
+
+func TestTray_OpenQuitAndCustomItems(t *testing.T) {
+	addr := privateBus(t)
+	watcher := startFakeWatcher(t, addr)
+
+	opened := make(chan struct{}, 4)
+	quit := make(chan struct{}, 1)
+	custom := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	err := startTray(ctx, trayConfig{
+		id:     "br.tec.lew.test.tray",
+		title:  "Tray test",
+		icon:   defaultTrayIcon,
+		items:  []TrayItem{{Label: "Sync now", OnClick: func() { custom <- struct{}{} }}},
+		onOpen: func() { opened <- struct{}{} },
+		onQuit: func() { quit <- struct{}{} },
+		bus:    busAt(addr),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service string
+	select {
+	case service = <-watcher.registered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("tray did not register with the watcher")
+	}
+
+	client, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	item := client.Object(service, sniPath)
+	id, err := item.GetProperty(sniInterface + ".Id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := id.Value().(string); got != "br.tec.lew.test.tray" {
+		t.Fatalf("Id = %v", id.Value())
+	}
+
+	menu := client.Object(service, menuPath)
+	var revision uint32
+	var layout menuLayout
+	if err := menu.Call(menuInterface+".GetLayout", 0, int32(0), int32(-1), []string{}).Store(&revision, &layout); err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	ids := map[string]int32{}
+	for _, child := range layout.Children {
+		var node menuLayout
+		if err := dbus.Store([]any{child.Value()}, &node); err != nil {
+			t.Fatal(err)
+		}
+		if l, ok := node.Properties["label"]; ok {
+			label, _ := l.Value().(string)
+			labels = append(labels, label)
+			ids[label] = node.ID
+		}
+	}
+	if got := strings.Join(labels, ","); got != "Open,Sync now,Quit" {
+		t.Fatalf("menu labels = %q", got)
+	}
+
+	click := func(id int32) {
+		t.Helper()
+		if err := menu.Call(menuInterface+".Event", 0, id, "clicked", dbus.MakeVariant(""), uint32(0)).Err; err != nil {
+			t.Fatal(err)
+		}
+	}
+	click(ids["Open"])
+	waitCalled(t, opened, "Open")
+	click(ids["Sync now"])
+	waitCalled(t, custom, "custom item")
+	if err := item.Call(sniInterface+".Activate", 0, int32(0), int32(0)).Err; err != nil {
+		t.Fatal(err)
+	}
+	waitCalled(t, opened, "Activate")
+	click(ids["Quit"])
+	waitCalled(t, quit, "Quit")
+}
+
+func TestTray_NoWatcher(t *testing.T) {
+	addr := privateBus(t)
+	err := startTray(t.Context(), trayConfig{id: "br.tec.lew.test.tray", onOpen: func() {}, onQuit: func() {}, bus: busAt(addr)})
+	if !errors.Is(err, ErrTrayUnavailable) {
+		t.Fatalf("want ErrTrayUnavailable, got %v", err)
+	}
+}
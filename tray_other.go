@@ -0,0 +1,10 @@
+//go:build !linux || android
+
+package eletrocromo
+
+import "context"
+
+// startTray has no CGo-less implementation off Linux (SPEC: Linux-first).
+func startTray(ctx context.Context, cfg trayConfig) error {
+	return ErrTrayUnsupported
+}
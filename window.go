@@ -0,0 +1,148 @@
+package eletrocromo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrWindowFlagDenied is returned by Run when WindowOptions.ExtraFlags holds
+// a flag eletrocromo owns or one that weakens the browser's isolation.
+var ErrWindowFlagDenied = errors.New("helium flag not allowed")
+
+// ErrWindowOptions is returned by Run for out-of-range WindowOptions.
+var ErrWindowOptions = errors.New("invalid window options")
+
+// WindowOptions shapes the Helium --app window (App.Window). The zero value
+// is Helium's default window.
+type WindowOptions struct {
+	// Width and Height set the initial size in DIPs (--window-size); both or
+	// neither.
+	Width, Height int
+	// Position places the window's top-left corner (--window-position).
+	Position *WindowPosition
+	// Scale forces the device scale factor (--force-device-scale-factor),
+	// e.g. 1.5; zero keeps the display's.
+	Scale float64
+	// Kiosk opens full-screen without browser UI (--kiosk).
+	Kiosk bool
+	// DarkMode forces dark browser UI and prefers-color-scheme: dark
+	// (--force-dark-mode).
+	DarkMode bool
+	// NoRestoreBounds opens at Helium's default (or Width/Height/Position)
+	// placement instead of where the window was last closed. Run remembers
+	// the last bounds per App.ID unless this or Kiosk is set.
+	NoRestoreBounds bool
+	// ExtraFlags are passed to Helium as is ("--name" or "--name=value"),
+	// after the flags above. Flags that eletrocromo owns (--app,
+	// --user-data-dir, remote debugging) or that disable sandboxing, site
+	// isolation, web security or the loopback host checks are refused with
+	// ErrWindowFlagDenied, as is --disable-features naming a security
+	// feature.
+	ExtraFlags []string
+}
+
+// WindowPosition is a screen position in DIPs.
+type WindowPosition struct {
+	X, Y int
+}
+
+// deniedWindowFlags are refused in ExtraFlags (compared without the value).
+var deniedWindowFlags = map[string]bool{
+	// Owned by eletrocromo.
+	"--app":                       true,
+	"--user-data-dir":             true,
+	"--profile-directory":         true,
+	"--remote-debugging-port":     true,
+	"--remote-debugging-pipe":     true,
+	"--remote-debugging-io-pipes": true,
+	"--remote-debugging-address":  true,
+	"--remote-allow-origins":      true,
+	// Sandbox and web security.
+	"--no-sandbox":                               true,
+	"--disable-setuid-sandbox":                   true,
+	"--disable-gpu-sandbox":                      true,
+	"--no-zygote":                                true,
+	"--single-process":                           true,
+	"--disable-web-security":                     true,
+	"--disable-site-isolation-trials":            true,
+	"--allow-running-insecure-content":           true,
+	"--allow-file-access-from-files":             true,
+	"--unsafely-treat-insecure-origin-as-secure": true,
+	// Would point the app's loopback host elsewhere (DNS rebinding).
+	"--host-resolver-rules": true,
+	// Run arbitrary commands around child processes.
+	"--renderer-cmd-prefix":     true,
+	"--utility-cmd-prefix":      true,
+	"--gpu-launcher":            true,
+	"--browser-subprocess-path": true,
+	// Load code into the app's profile.
+	"--load-extension": true,
+}
+
+// deniedDisabledFeatures are refused in --disable-features (lower case).
+var deniedDisabledFeatures = map[string]bool{
+	"siteperprocess":                      true,
+	"isolateorigins":                      true,
+	"strictoriginisolation":               true,
+	"originisolationheader":               true,
+	"isolatesandboxediframes":             true,
+	"networkservicesandbox":               true,
+	"blockinsecureprivatenetworkrequests": true,
+}
+
+// flags maps o to Helium command-line flags.
+func (o WindowOptions) flags() ([]string, error) {
+	var flags []string
+	switch {
+	case o.Width < 0 || o.Height < 0 || (o.Width == 0) != (o.Height == 0):
+		return nil, fmt.Errorf("%w: size %dx%d", ErrWindowOptions, o.Width, o.Height)
+	case o.Width > 0:
+		flags = append(flags, fmt.Sprintf("--window-size=%d,%d", o.Width, o.Height))
+	}
+	if o.Position != nil {
+		flags = append(flags, fmt.Sprintf("--window-position=%d,%d", o.Position.X, o.Position.Y))
+	}
+	switch {
+	case o.Scale < 0:
+		return nil, fmt.Errorf("%w: scale %v", ErrWindowOptions, o.Scale)
+	case o.Scale > 0:
+		flags = append(flags, "--force-device-scale-factor="+strconv.FormatFloat(o.Scale, 'f', -1, 64))
+	}
+	if o.Kiosk {
+		flags = append(flags, "--kiosk")
+	}
+	if o.DarkMode {
+		flags = append(flags, "--force-dark-mode")
+	}
+	for _, f := range o.ExtraFlags {
+		if err := checkWindowFlag(f); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, nil
+}
+
+func checkWindowFlag(f string) error {
+	name, value, _ := strings.Cut(f, "=")
+	name = strings.ToLower(name)
+	if !strings.HasPrefix(name, "--") || len(name) == 2 {
+		// Positional args would be opened as extra URLs.
+		return fmt.Errorf("%w: %q is not a --flag", ErrWindowFlagDenied, f)
+	}
+	if deniedWindowFlags[name] {
+		return fmt.Errorf("%w: %s", ErrWindowFlagDenied, name)
+	}
+	if name == "--disable-features" {
+		for _, feature := range strings.Split(value, ",") {
+			// "Name<Trial" ties a feature to a field trial.
+			feature, _, _ = strings.Cut(feature, "<")
+			if deniedDisabledFeatures[strings.ToLower(strings.TrimSpace(feature))] {
+				return fmt.Errorf("%w: %s=%s", ErrWindowFlagDenied, name, feature)
+			}
+		}
+	}
+	return nil
+}
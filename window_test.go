@@ -0,0 +1,118 @@
+package eletrocromo
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWindowOptions_Flags(t *testing.T) {
+	got, err := WindowOptions{
+		Width: 420, Height: 720,
+		Position:   &WindowPosition{X: 10, Y: -20},
+		Scale:      1.5,
+		Kiosk:      true,
+		DarkMode:   true,
+		ExtraFlags: []string{"--lang=pt-BR", "--disable-pinch"},
+	}.flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"--window-size=420,720",
+		"--window-position=10,-20",
+		"--force-device-scale-factor=1.5",
+		"--kiosk",
+		"--force-dark-mode",
+		"--lang=pt-BR",
+		"--disable-pinch",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("flags = %q, want %q", got, want)
+	}
+	if got, err := (WindowOptions{}).flags(); err != nil || len(got) != 0 {
+		t.Fatalf("zero value: %q, %v", got, err)
+	}
+}
+
+func TestWindowOptions_Rejects(t *testing.T) {
+	denied := []string{
+		"--no-sandbox",
+		"--NO-SANDBOX",
+		"--user-data-dir=/tmp/x",
+		"--app=http://evil.example",
+		"--remote-debugging-port=9222",
+		"--disable-web-security",
+		"--renderer-cmd-prefix=gdb",
+		"--host-resolver-rules=MAP * 203.0.113.7",
+		"--unsafely-treat-insecure-origin-as-secure=http://evil.example",
+		"--allow-file-access-from-files",
+		"--remote-debugging-io-pipes=cbor",
+		"--disable-features=IsolateOrigins",
+		"--disable-features=Translate,SitePerProcess",
+		"--disable-features=siteperprocess<Trial",
+		"-no-sandbox",
+		"http://evil.example",
+		"--",
+	}
+	for _, f := range denied {
+		if _, err := (WindowOptions{ExtraFlags: []string{f}}).flags(); !errors.Is(err, ErrWindowFlagDenied) {
+			t.Errorf("%q: err = %v", f, err)
+		}
+	}
+	// Other features may still be turned off.
+	if _, err := (WindowOptions{ExtraFlags: []string{"--disable-features=Translate,MediaRouter"}}).flags(); err != nil {
+		t.Errorf("--disable-features=Translate,MediaRouter: %v", err)
+	}
+	invalid := []WindowOptions{
+		{Width: 420},
+		{Width: -1, Height: 10},
+		{Scale: -1},
+	}
+	for _, o := range invalid {
+		if _, err := o.flags(); !errors.Is(err, ErrWindowOptions) {
+			t.Errorf("%+v: err = %v", o, err)
+		}
+	}
+}
+
+func TestRun_WindowFlagsReachHelium(t *testing.T) {
+	script, launches := fakeHeliumScript(t, "0.3")
+	host := scriptHost(t, script)
+
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.window_flags"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+		WithWindow(WindowOptions{Width: 420, Height: 720, Kiosk: true, ExtraFlags: []string{"--lang=pt-BR"}}),
+	)
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(launches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argv := strings.Fields(string(b))
+	i := slices.Index(argv, "--window-size=420,720")
+	j := slices.IndexFunc(argv, func(a string) bool { return strings.HasPrefix(a, "--app=") })
+	if i < 0 || j < i || !slices.Contains(argv, "--kiosk") || !slices.Contains(argv, "--lang=pt-BR") {
+		t.Fatalf("argv = %q", argv)
+	}
+}
+
+func TestRun_DeniedWindowFlagFailsFast(t *testing.T) {
+	host := scriptHost(t, "/nonexistent/helium")
+	app := New(http.NotFoundHandler(),
+		WithID("br.tec.lew.test.window_denied"),
+		WithContext(t.Context()),
+		WithBrowserHost(host),
+		WithWindow(WindowOptions{ExtraFlags: []string{"--no-sandbox"}}),
+	)
+	if err := app.Run(); !errors.Is(err, ErrWindowFlagDenied) {
+		t.Fatalf("err = %v", err)
+	}
+}
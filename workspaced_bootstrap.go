@@ -81,8 +81,8 @@ var bootstrapHTTP = &http.Client{
 	},
 }
 
-// httpGet is bootstrapHTTP.Do wrapper; tests may override.
-var httpGet = func(ctx context.Context, url string) (*http.Response, error) {
+// bootstrapGet is a GET through bootstrapHTTP.
+func bootstrapGet(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -90,6 +90,14 @@ var httpGet = func(ctx context.Context, url string) (*http.Response, error) {
 	return bootstrapHTTP.Do(req)
 }
 
+// get is bootstrapGet unless a test set h.httpGet.
+func (h *HeliumHost) get(ctx context.Context, url string) (*http.Response, error) {
+	if h.httpGet != nil {
+		return h.httpGet(ctx, url)
+	}
+	return bootstrapGet(ctx, url)
+}
+
 func workspacedAssetName() (string, error) {
 	var osPart string
 	switch runtime.GOOS {
@@ -131,7 +139,8 @@ func workspacedCacheDir() (string, error) {
 // bootstrapWorkspaced downloads a pinned workspaced release into the user cache
 // (if missing), verifies the archive SHA-256, extracts the binary, and returns
 // its path.
-func bootstrapWorkspaced(ctx context.Context) (string, error) {
+func (h *HeliumHost) bootstrapWorkspaced(ctx context.Context) (string, error) {
+	lg := h.logger()
 	asset, err := workspacedAssetName()
 	if err != nil {
 		return "", err
@@ -159,7 +168,8 @@ func bootstrapWorkspaced(ctx context.Context) (string, error) {
 	}
 
 	url := workspacedReleaseBase + "/" + asset
-	resp, err := httpGet(ctx, url)
+	lg.Info("downloading workspaced", "url", url, "dir", dir)
+	resp, err := h.get(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("bootstrap workspaced: download: %w", err)
 	}
@@ -174,8 +184,8 @@ func bootstrapWorkspaced(ctx context.Context) (string, error) {
 		_ = resp.Body.Close()
 		return "", err
 	}
-	h := sha256.New()
-	w := io.MultiWriter(f, h)
+	sum := sha256.New()
+	w := io.MultiWriter(f, sum)
 	// body.Close closes the HTTP response body (idleTimeoutReader wraps it).
 	body := newIdleTimeoutReader(resp.Body, downloadIdleTimeout)
 	_, copyErr := io.Copy(w, body)
@@ -193,7 +203,7 @@ func bootstrapWorkspaced(ctx context.Context) (string, error) {
 		removeBestEffort(archivePath)
 		return "", fileCloseErr
 	}
-	got := hex.EncodeToString(h.Sum(nil))
+	got := hex.EncodeToString(sum.Sum(nil))
 	if got != wantSum {
 		removeBestEffort(archivePath)
 		return "", fmt.Errorf("%w for %s: got %s want %s", ErrWorkspacedChecksumMismatch, asset, got, wantSum)
@@ -207,6 +217,7 @@ func bootstrapWorkspaced(ctx context.Context) (string, error) {
 		removeBestEffort(binPath)
 		return "", err
 	}
+	lg.Info("workspaced bootstrapped", "bin", binPath)
 	return binPath, nil
 }
 